@@ -17,20 +17,96 @@ limitations under the License.
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/gabriellasaro/load-test/load"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+// exitThresholdsFailed is the exit code when a threshold is not satisfied.
+// Other errors exit with 1.
+const exitThresholdsFailed = 99
+
+type outputs []string
+
+func (o *outputs) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *outputs) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// outputsForFile adds the name of the test file to the destination of the
+// outputs when more than one file is executed, so the reports are not
+// overwritten.
+func outputsForFile(out outputs, filename string, multiple bool) []string {
+	if !multiple {
+		return out
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	result := make([]string, len(out))
+	for i, value := range out {
+		kind, destination, _ := strings.Cut(value, "=")
+		ext := filepath.Ext(destination)
+		result[i] = kind + "=" + strings.TrimSuffix(destination, ext) + "." + name + ext
+	}
+
+	return result
+}
+
+// parseArguments parses the flags before and after the file names, as in
+// "load-test test.json --out json=summary.json", and returns the file names.
+// The arguments after "--" are all file names.
+func parseArguments(flags *flag.FlagSet, arguments []string) []string {
+	var filenames []string
+
+	for {
+		_ = flags.Parse(arguments)
+
+		consumed := arguments[:len(arguments)-flags.NArg()]
+		if len(consumed) > 0 && consumed[len(consumed)-1] == "--" || flags.NArg() == 0 {
+			return append(filenames, flags.Args()...)
+		}
+
+		filenames = append(filenames, flags.Arg(0))
+		arguments = flags.Args()[1:]
+	}
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Printf("Informe o arquivo para ser executado: %s filename.json", os.Args[0])
-	} else {
-		for _, filename := range os.Args[1:] {
-			err := load.Run(filename)
-			if err != nil {
-				fmt.Println(err)
+	var out outputs
+	flag.Var(&out, "out", "saída do resultado no formato tipo=destino, ex.: json=summary.json")
+
+	filenames := parseArguments(flag.CommandLine, os.Args[1:])
+
+	if len(filenames) < 1 {
+		fmt.Printf("Informe o arquivo para ser executado: %s [--out json=summary.json] filename.json\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	exitCode := 0
+
+	for _, filename := range filenames {
+		err := load.Run(filename, &load.Options{
+			Outputs: outputsForFile(out, filename, len(filenames) > 1),
+		})
+		if err != nil {
+			fmt.Println(err)
+
+			if errors.Is(err, load.ErrThresholdsFailed) {
+				exitCode = exitThresholdsFailed
+			} else if exitCode == 0 {
+				exitCode = 1
 			}
 		}
 	}
+
+	os.Exit(exitCode)
 }
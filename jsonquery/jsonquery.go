@@ -0,0 +1,381 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsonquery searches values in decoded JSON documents with a subset of
+// JMESPath, also accepting the JSONPath root ($) and plain dotted paths:
+//
+//	data.token                   field
+//	items[0].id, items[-1].id    index, negative from the end
+//	items.0.id                   index as a dotted segment
+//	items[*].id, data.*          wildcard projection
+//	items[?status=='open'].id    filter projection (==, !=, <, <=, >, >=)
+//	matrix[]                     flatten
+//	length(items)                length of an array, object or string
+//	$.data['some key']           JSONPath root and quoted fields
+//
+// Documents must be decoded into interface{}; numbers may be float64 or
+// json.Number.
+package jsonquery
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepWildcard
+	stepFlatten
+	stepFilter
+)
+
+type step struct {
+	kind   stepKind
+	name   string
+	index  int
+	dotted bool
+	filter *filter
+}
+
+type filter struct {
+	left     *Query
+	operator string
+	right    interface{}
+}
+
+// Query is a compiled expression, safe for concurrent use.
+type Query struct {
+	expression string
+	function   string
+	argument   *Query
+	steps      []step
+}
+
+// Compile parses the expression.
+func Compile(expression string) (*Query, error) {
+	p := &parser{input: expression}
+
+	q, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if !p.end() {
+		return nil, p.errorf("unexpected character %q", p.peek())
+	}
+
+	return q, nil
+}
+
+// Search compiles the expression and applies it to the document.
+func Search(expression string, document interface{}) (interface{}, error) {
+	q, err := Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Search(document), nil
+}
+
+func (q *Query) String() string {
+	return q.expression
+}
+
+// Search returns the value found in the document, or nil when the path does
+// not exist.
+func (q *Query) Search(document interface{}) interface{} {
+	if q.function != "" {
+		return length(q.argument.Search(document))
+	}
+
+	return evaluate(document, q.steps)
+}
+
+func evaluate(value interface{}, steps []step) interface{} {
+	for i, st := range steps {
+		switch st.kind {
+		case stepField:
+			value = field(value, st)
+		case stepIndex:
+			value = index(value, st.index)
+		case stepWildcard:
+			return project(elements(value), steps[i+1:])
+		case stepFlatten:
+			return project(flatten(value), steps[i+1:])
+		case stepFilter:
+			return project(st.filter.apply(value), steps[i+1:])
+		}
+
+		if value == nil {
+			return nil
+		}
+	}
+
+	return value
+}
+
+func field(value interface{}, st step) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v[st.name]
+	case []interface{}:
+		// a numeric dotted segment (items.0.id) is an index of the array.
+		if st.dotted {
+			if i, err := strconv.Atoi(st.name); err == nil {
+				return index(v, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+func index(value interface{}, i int) interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	if i < 0 {
+		i += len(array)
+	}
+
+	if i < 0 || i >= len(array) {
+		return nil
+	}
+
+	return array[i]
+}
+
+func elements(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+func flatten(value interface{}) []interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	flattened := make([]interface{}, 0, len(array))
+	for _, element := range array {
+		if inner, ok := element.([]interface{}); ok {
+			flattened = append(flattened, inner...)
+		} else {
+			flattened = append(flattened, element)
+		}
+	}
+
+	return flattened
+}
+
+// project applies the remaining steps to each element, discarding the
+// elements where the path does not exist. A flatten ends the projection and
+// applies to the projected values, as in items[*].tags[].
+func project(values []interface{}, steps []step) interface{} {
+	if values == nil {
+		return nil
+	}
+
+	var rest []step
+
+	for i, st := range steps {
+		if st.kind == stepFlatten {
+			steps, rest = steps[:i], steps[i:]
+			break
+		}
+	}
+
+	projected := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if result := evaluate(value, steps); result != nil {
+			projected = append(projected, result)
+		}
+	}
+
+	if rest != nil {
+		return evaluate(projected, rest)
+	}
+
+	return projected
+}
+
+func (f *filter) apply(value interface{}) []interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	matches := make([]interface{}, 0, len(array))
+	for _, element := range array {
+		left := f.left.Search(element)
+
+		if f.operator == "" && truthy(left) || f.operator != "" && compare(left, f.operator, f.right) {
+			matches = append(matches, element)
+		}
+	}
+
+	return matches
+}
+
+func length(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return json.Number(strconv.Itoa(utf8.RuneCountInString(v)))
+	case []interface{}:
+		return json.Number(strconv.Itoa(len(v)))
+	case map[string]interface{}:
+		return json.Number(strconv.Itoa(len(v)))
+	default:
+		return nil
+	}
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func compare(left interface{}, operator string, right interface{}) bool {
+	if l, ok := number(left); ok {
+		if r, ok := number(right); ok {
+			switch {
+			case l < r:
+				return compareResult(-1, operator)
+			case l > r:
+				return compareResult(1, operator)
+			default:
+				return compareResult(0, operator)
+			}
+		}
+	}
+
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareResult(strings.Compare(l, r), operator)
+		}
+	}
+
+	switch operator {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	default:
+		return false
+	}
+}
+
+// compareResult applies the operator to the result of a three-way comparison.
+func compareResult(result int, operator string) bool {
+	switch operator {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	default:
+		return result >= 0
+	}
+}
+
+func equal(left, right interface{}) bool {
+	l, err := json.Marshal(left)
+	if err != nil {
+		return false
+	}
+
+	r, err := json.Marshal(right)
+	if err != nil {
+		return false
+	}
+
+	return string(l) == string(r)
+}
+
+// Format returns strings as they are and any other value encoded as JSON.
+func Format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		content, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+
+		return string(content), nil
+	}
+}
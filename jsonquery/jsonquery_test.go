@@ -0,0 +1,221 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonquery
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const document = `{
+	"data": {"token": "abc", "count": 3, "ok": true, "empty": null, "some key": "spaced"},
+	"items": [
+		{"id": 1, "status": "open", "tags": ["a", "b"]},
+		{"id": 2, "status": "closed", "tags": ["c"]},
+		{"id": 3, "status": "open", "tags": []}
+	],
+	"matrix": [[1, 2], [3], 4],
+	"numbers": [10, 20, 30]
+}`
+
+func decode(t *testing.T, text string) interface{} {
+	t.Helper()
+
+	var value interface{}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func TestSearch(t *testing.T) {
+	doc := decode(t, document)
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"data.token", `abc`},
+		{"$.data.token", `abc`},
+		{"@.data.count", `3`},
+		{"data.ok", `true`},
+		{"data", `{"count":3,"empty":null,"ok":true,"some key":"spaced","token":"abc"}`},
+		{"$.data['some key']", `spaced`},
+		{`data."some key"`, `spaced`},
+		{"items[0].id", `1`},
+		{"items.1.id", `2`},
+		{"items[-1].id", `3`},
+		{"items[-2].status", `closed`},
+		{"items[*].id", `[1,2,3]`},
+		{"items[*].tags[0]", `["a","c"]`},
+		{"data.*", `[3,true,"spaced","abc"]`},
+		{"items[?status=='open'].id", `[1,3]`},
+		{`items[?status == "closed"].id`, `[2]`},
+		{"items[?status!='open'].id", `[2]`},
+		{"items[?id>=2].id", `[2,3]`},
+		{"items[?id<2].status", `["open"]`},
+		{"items[?id==`3`].status", `["open"]`},
+		{"items[*].tags[]", `["a","b","c"]`},
+		{"items[?status=='open'].tags[]", `["a","b"]`},
+		{"length(items[*].tags[])", `3`},
+		{"matrix[]", `[1,2,3,4]`},
+		{"matrix[0][1]", `2`},
+		{"length(items)", `3`},
+		{"length(data.token)", `3`},
+		{"length(data)", `5`},
+		{"numbers[1]", `20`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			value, err := Search(tt.expression, doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value == nil {
+				t.Fatalf("the path was not found")
+			}
+
+			result, err := Format(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("got %s, expected %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSearchRoot(t *testing.T) {
+	tests := []struct {
+		document   string
+		expression string
+		expected   string
+	}{
+		{`[1, 2, 3]`, "[0]", `1`},
+		{`[1, 2, 3]`, "[-1]", `3`},
+		{`[1, 2, 3]`, "@", `[1,2,3]`},
+		{`[1, 2, 3]`, "length(@)", `3`},
+		{`"text"`, "@", `text`},
+		{`42`, "$", `42`},
+		{`[{"a": 1}, {"a": 2}]`, "[*].a", `[1,2]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.document+" "+tt.expression, func(t *testing.T) {
+			value, err := Search(tt.expression, decode(t, tt.document))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := Format(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("got %s, expected %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSearchNotFound(t *testing.T) {
+	doc := decode(t, document)
+
+	for _, expression := range []string{
+		"data.missing",
+		"data.empty",
+		"data.token.inner",
+		"items[10].id",
+		"items[-4].id",
+		"numbers.x",
+		"missing[0]",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			value, err := Search(expression, doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != nil {
+				t.Errorf("got %v, expected nil", value)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		column     string
+	}{
+		{"items[", "column 7"},
+		{"items[?status=='open'", "column 22"},
+		{"items[?status=='open]", "column 16"},
+		{"items[x]", "column 7"},
+		{"length(items", "column 13"},
+		{"data..token", "column 6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Compile(tt.expression)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if !strings.Contains(err.Error(), tt.column+":") {
+				t.Errorf("got %q, expected the %s", err.Error(), tt.column)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"a \"quoted\" text", `a "quoted" text`},
+		{json.Number("1.50"), `1.50`},
+		{float64(2), `2`},
+		{true, `true`},
+		{[]interface{}{"a", json.Number("1")}, `["a",1]`},
+		{map[string]interface{}{"b": 1, "a": "x"}, `{"a":"x","b":1}`},
+	}
+
+	for _, tt := range tests {
+		result, err := Format(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result != tt.expected {
+			t.Errorf("got %s, expected %s", result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var functions = map[string]bool{
+	"length": true,
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid query (%s) at column %d: %s", p.input, p.pos+1, fmt.Sprintf(format, a...))
+}
+
+func (p *parser) end() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	if p.end() {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
+
+func (p *parser) skipSpaces() {
+	for !p.end() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(prefix string) bool {
+	if strings.HasPrefix(p.input[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+
+	return false
+}
+
+func isIdentifier(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *parser) identifier() string {
+	start := p.pos
+	for !p.end() && isIdentifier(p.peek()) {
+		p.pos++
+	}
+
+	return p.input[start:p.pos]
+}
+
+func (p *parser) parseExpression() (*Query, error) {
+	p.skipSpaces()
+	start := p.pos
+
+	name := p.identifier()
+	if functions[name] && p.consume("(") {
+		argument, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+
+		return &Query{expression: p.input[start:p.pos], function: name, argument: argument}, nil
+	}
+
+	p.pos = start
+
+	return p.parsePath()
+}
+
+// parsePath parses fields, indexes, wildcards, flattens and filters until
+// the end of the path (end of input, space, operator, ] or )).
+func (p *parser) parsePath() (*Query, error) {
+	start := p.pos
+	q := new(Query)
+
+	if p.consume("$") || p.consume("@") {
+		p.consume(".")
+	}
+
+	expectSegment := true
+
+	for !p.end() {
+		c := p.peek()
+
+		switch {
+		case c == '[':
+			p.pos++
+
+			st, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+
+			q.steps = append(q.steps, st)
+			expectSegment = false
+		case c == '.' && !expectSegment:
+			p.pos++
+			expectSegment = true
+		case expectSegment && c == '*':
+			p.pos++
+			q.steps = append(q.steps, step{kind: stepWildcard})
+			expectSegment = false
+		case expectSegment && (c == '"' || c == '\''):
+			name, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+
+			q.steps = append(q.steps, step{kind: stepField, name: name})
+			expectSegment = false
+		case expectSegment && isIdentifier(c):
+			q.steps = append(q.steps, step{kind: stepField, name: p.identifier(), dotted: true})
+			expectSegment = false
+		default:
+			if expectSegment && len(q.steps) > 0 {
+				return nil, p.errorf("expected a field after .")
+			}
+
+			q.expression = p.input[start:p.pos]
+
+			return q, nil
+		}
+	}
+
+	if expectSegment && len(q.steps) > 0 {
+		return nil, p.errorf("expected a field after .")
+	}
+
+	q.expression = p.input[start:p.pos]
+
+	return q, nil
+}
+
+func (p *parser) parseBracket() (step, error) {
+	p.skipSpaces()
+
+	var st step
+
+	switch c := p.peek(); {
+	case c == ']':
+		st.kind = stepFlatten
+	case c == '*':
+		p.pos++
+		st.kind = stepWildcard
+	case c == '?':
+		p.pos++
+
+		f, err := p.parseFilter()
+		if err != nil {
+			return st, err
+		}
+
+		st.kind = stepFilter
+		st.filter = f
+	case c == '"' || c == '\'':
+		name, err := p.parseString()
+		if err != nil {
+			return st, err
+		}
+
+		st.kind = stepField
+		st.name = name
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for !p.end() && p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+
+		i, err := strconv.Atoi(p.input[start:p.pos])
+		if err != nil {
+			p.pos = start
+			return st, p.errorf("invalid index")
+		}
+
+		st.kind = stepIndex
+		st.index = i
+	case p.end():
+		return st, p.errorf("unexpected end of the query in brackets")
+	default:
+		return st, p.errorf("unexpected character %q in brackets", c)
+	}
+
+	p.skipSpaces()
+	if !p.consume("]") {
+		return st, p.errorf("expected ]")
+	}
+
+	return st, nil
+}
+
+func (p *parser) parseFilter() (*filter, error) {
+	left, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &filter{left: left}
+
+	p.skipSpaces()
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(operator) {
+			f.operator = operator
+			break
+		}
+	}
+
+	if f.operator == "" {
+		return f, nil
+	}
+
+	p.skipSpaces()
+
+	right, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+
+	f.right = right
+
+	return f, nil
+}
+
+func (p *parser) parseString() (string, error) {
+	start := p.pos
+	quote := p.peek()
+	p.pos++
+
+	var value strings.Builder
+	for !p.end() {
+		c := p.peek()
+		p.pos++
+
+		switch {
+		case c == '\\' && !p.end():
+			value.WriteByte(p.peek())
+			p.pos++
+		case c == quote:
+			return value.String(), nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+
+	p.pos = start
+
+	return "", p.errorf("unterminated string")
+}
+
+// parseLiteral parses the right side of a filter: a quoted string, a number,
+// true, false, null or a JSON value between backticks.
+func (p *parser) parseLiteral() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '`':
+		end := strings.IndexByte(p.input[p.pos+1:], '`')
+		if end < 0 {
+			return nil, p.errorf("unterminated literal")
+		}
+
+		var value interface{}
+
+		decoder := json.NewDecoder(strings.NewReader(p.input[p.pos+1 : p.pos+1+end]))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, p.errorf("invalid JSON literal: %s", err.Error())
+		}
+
+		p.pos += end + 2
+
+		return value, nil
+	}
+
+	start := p.pos
+	word := p.identifier()
+	if p.consume(".") {
+		word += "." + p.identifier()
+	}
+
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	if _, err := strconv.ParseFloat(word, 64); err != nil || word == "" {
+		p.pos = start
+		return nil, p.errorf("invalid literal")
+	}
+
+	return json.Number(word), nil
+}
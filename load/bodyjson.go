@@ -0,0 +1,149 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gabriellasaro/load-test/template"
+	"sort"
+)
+
+// jsonMember is a member of an object of body_json.
+type jsonMember struct {
+	key   *template.Template
+	value interface{}
+}
+
+// parseJSONTemplate parses the strings of body_json, keys included, as
+// templates. Objects become []jsonMember and the other values are kept.
+func (s *Step) parseJSONTemplate(field string, value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		return s.parseTemplate(field, value)
+	case []interface{}:
+		array := make([]interface{}, len(value))
+
+		for i, item := range value {
+			parsed, err := s.parseJSONTemplate(fmt.Sprintf("%s[%d]", field, i), item)
+			if err != nil {
+				return nil, err
+			}
+
+			array[i] = parsed
+		}
+
+		return array, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		object := make([]jsonMember, len(keys))
+
+		for i, key := range keys {
+			parsedKey, err := s.parseTemplate(fmt.Sprintf("%s key (%s)", field, key), key)
+			if err != nil {
+				return nil, err
+			}
+
+			parsed, err := s.parseJSONTemplate(field+"."+key, value[key])
+			if err != nil {
+				return nil, err
+			}
+
+			object[i] = jsonMember{key: parsedKey, value: parsed}
+		}
+
+		return object, nil
+	default:
+		return value, nil
+	}
+}
+
+// renderJSON executes the templates of body_json. A string that is only one
+// tag, without a filter, is replaced by the value of the tag with its type;
+// the other strings remain strings. Two keys of an object that render the
+// same text are an error.
+func (s *Step) renderJSON(value interface{}, it *iteration) (interface{}, error) {
+	switch value := value.(type) {
+	case *template.Template:
+		tag, ok := value.Single()
+		if !ok || tag.Filter != "" {
+			return s.render(value, it)
+		}
+
+		return tag.ExecuteValue(s.resolver(it), func(tag *template.Tag, fields []string) (interface{}, error) {
+			return s.resolveValue(it, tag, fields)
+		})
+	case []interface{}:
+		array := make([]interface{}, len(value))
+
+		for i, item := range value {
+			rendered, err := s.renderJSON(item, it)
+			if err != nil {
+				return nil, err
+			}
+
+			array[i] = rendered
+		}
+
+		return array, nil
+	case []jsonMember:
+		object := make(map[string]interface{}, len(value))
+
+		for _, member := range value {
+			key, err := s.render(member.key, it)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := object[key]; ok {
+				return nil, fmt.Errorf("the key (%s) is duplicated", key)
+			}
+
+			rendered, err := s.renderJSON(member.value, it)
+			if err != nil {
+				return nil, err
+			}
+
+			object[key] = rendered
+		}
+
+		return object, nil
+	default:
+		return value, nil
+	}
+}
+
+// decodeJSONValue decodes a JSON value keeping the numbers as json.Number.
+func decodeJSONValue(text string) (interface{}, error) {
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("the value is not valid JSON: %s", err.Error())
+	}
+
+	return value, nil
+}
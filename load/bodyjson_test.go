@@ -0,0 +1,90 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBodyJSONKeys(t *testing.T) {
+	s := &Step{index: 1}
+
+	_, err := s.parseJSONTemplate("body_json", map[string]interface{}{
+		"user": map[string]interface{}{"{%VAR:k": 1},
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "cycle[1].body_json.user key ({%VAR:k): column 1:") {
+		t.Errorf("got %v, expected the error under the key", err)
+	}
+
+	body, err := s.parseJSONTemplate("body_json", map[string]interface{}{
+		"a":                1,
+		"{%VAR:k:ENDVAR%}": 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := &iteration{variables: map[string]string{"k": "b"}, session: new(session)}
+
+	value, err := s.renderJSON(body, it)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if object := value.(map[string]interface{}); len(object) != 2 || object["b"] != 2 {
+		t.Errorf("got %v, expected the rendered key", object)
+	}
+
+	it.variables["k"] = "a"
+
+	_, err = s.renderJSON(body, it)
+	if err == nil || err.Error() != "the key (a) is duplicated" {
+		t.Errorf("got %v, expected the duplicated key", err)
+	}
+}
+
+func TestBodyJSONNumbers(t *testing.T) {
+	sc := &Scenario{Cycle: []byte(`[{"body_json": {"big": 12345678901234567890, "float": 1.50, "list": [1e3, -0]}}]`)}
+
+	cycle, err := sc.newCycle()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := cycle.Steps[0]
+
+	body, err := s.parseJSONTemplate("body_json", s.BodyJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := s.renderJSON(body, &iteration{session: new(session)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"big":12345678901234567890,"float":1.50,"list":[1e3,-0]}`; string(content) != expected {
+		t.Errorf("got %s, expected %s", content, expected)
+	}
+}
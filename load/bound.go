@@ -0,0 +1,67 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// splitBoundaries splits left:right, where \: is a colon inside a boundary.
+func splitBoundaries(variable string) (string, string, error) {
+	parts := []string{""}
+
+	for i := 0; i < len(variable); i++ {
+		switch {
+		case variable[i] == '\\' && i+1 < len(variable) && variable[i+1] == ':':
+			parts[len(parts)-1] += ":"
+			i++
+		case variable[i] == ':':
+			parts = append(parts, "")
+		default:
+			parts[len(parts)-1] += string(variable[i])
+		}
+	}
+
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("the boundary variable must have a left and a right boundary: %q", variable)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// getValueByBoundaries returns the text between the first occurrence of the
+// left boundary and the next occurrence of the right one. An empty boundary
+// means the start or the end of the body.
+func (r *ResponseCycle) getValueByBoundaries(left, right string) (string, error) {
+	start := bytes.Index(r.Body, []byte(left))
+	if start < 0 {
+		return "", fmt.Errorf("the left boundary (%s) was not found in the response", left)
+	}
+
+	value := r.Body[start+len(left):]
+	if right == "" {
+		return string(value), nil
+	}
+
+	end := bytes.Index(value, []byte(right))
+	if end < 0 {
+		return "", fmt.Errorf("the right boundary (%s) was not found in the response", right)
+	}
+
+	return string(value[:end]), nil
+}
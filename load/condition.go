@@ -19,21 +19,22 @@ package load
 import (
 	"errors"
 	"fmt"
+	"github.com/gabriellasaro/load-test/template"
 	"strings"
+	"unicode/utf8"
 )
 
 type Condition struct {
 	equalityOperator string
-	arguments        [2]string
-	values           [2]string
+	arguments        [2]*template.Template
 }
 
-func (c *Condition) applyCondition() bool {
+func (c *Condition) applyCondition(values [2]string) bool {
 	switch c.equalityOperator {
 	case "==":
-		return c.values[0] == c.values[1]
+		return values[0] == values[1]
 	case "!=":
-		return c.values[0] != c.values[1]
+		return values[0] != values[1]
 	default:
 		return false
 	}
@@ -50,30 +51,67 @@ func validateEqualityOperator(op string) (operator string, err error) {
 	return
 }
 
-func (s *Step) getArgsOfCondition() []string {
+// splitCondition splits the condition on the spaces outside the tags.
+func splitCondition(condition string) []string {
 	args := make([]string, 0)
-	argsRaw := strings.Split(s.ConditionRaw.TrimSpace().String(), " ")
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(condition); i++ {
+		switch {
+		case strings.HasPrefix(condition[i:], `\{%`), strings.HasPrefix(condition[i:], `\%}`):
+			i += 2
+		case strings.HasPrefix(condition[i:], "{%"):
+			depth++
+			i++
+		case strings.HasPrefix(condition[i:], "%}") && depth > 0:
+			depth--
+			i++
+		case condition[i] == ' ' && depth == 0:
+			args = append(args, condition[start:i])
+			start = i + 1
+		}
+	}
 
-	var (
-		rightArgument string
-		argumentFound int
-	)
+	return append(args, condition[start:])
+}
 
-	for _, argRaw := range argsRaw {
-		if argumentFound == 2 {
-			rightArgument += " " + argRaw
-			continue
+// conditionArgument is an argument of the condition and the column where it
+// starts, used in the errors.
+type conditionArgument struct {
+	text   string
+	column int
+}
+
+// getArgsOfCondition returns the operator and the operands of the condition;
+// the right operand is the rest of the condition, spaces included.
+func (s *Step) getArgsOfCondition() []conditionArgument {
+	condition := s.ConditionRaw.String()
+	args := make([]conditionArgument, 0)
+	offset := 0
+
+	for _, argRaw := range splitCondition(condition) {
+		start := offset
+		offset += len(argRaw) + 1
+
+		if len(args) == 2 {
+			argRaw = condition[start:]
 		}
 
 		arg := strings.TrimSpace(argRaw)
-		if arg != "" {
-			args = append(args, strings.TrimSpace(arg))
-			argumentFound++
+		if arg == "" {
+			continue
 		}
-	}
 
-	if rightArgument != "" {
-		args = append(args, strings.TrimSpace(rightArgument))
+		start += strings.Index(argRaw, arg)
+		args = append(args, conditionArgument{
+			text:   arg,
+			column: utf8.RuneCountInString(condition[:start]) + 1,
+		})
+
+		if len(args) == 3 {
+			break
+		}
 	}
 
 	return args
@@ -95,45 +133,52 @@ func (s *Step) preloadIf() error {
 
 	s.condition = new(Condition)
 
-	operador, err := validateEqualityOperator(cond[0])
+	operador, err := validateEqualityOperator(cond[0].text)
 	if err != nil {
 		return err
 	}
 
 	s.condition.equalityOperator = operador
-	s.condition.arguments[0] = cond[1]
-	s.condition.arguments[1] = cond[2]
+
+	for i, argument := range cond[1:] {
+		t, err := s.parseTemplateAt("if", argument.text, argument.column)
+		if err != nil {
+			return err
+		}
+
+		s.condition.arguments[i] = t
+	}
 
 	return nil
 }
 
-func (s *Step) setValuesForCondition(variables []*Variable, cycle *[]*Step) error {
-	value0, err := s.applyVariables(variables, cycle, s.condition.arguments[0])
-	if err != nil {
-		return err
-	}
-	s.condition.values[0] = value0
+func (s *Step) valuesForCondition(it *iteration) ([2]string, error) {
+	var values [2]string
 
-	value1, err := s.applyVariables(variables, cycle, s.condition.arguments[1])
-	if err != nil {
-		return err
+	for i, argument := range s.condition.arguments {
+		value, err := s.render(argument, it)
+		if err != nil {
+			return values, err
+		}
+
+		values[i] = value
 	}
-	s.condition.values[1] = value1
 
-	return nil
+	return values, nil
 }
 
-func (s *Step) executeIf(variables []*Variable, cycle *[]*Step) error {
+func (s *Step) executeIf(it *iteration) error {
 	if s.condition == nil {
 		return nil
 	}
 
-	if err := s.setValuesForCondition(variables, cycle); err != nil {
+	values, err := s.valuesForCondition(it)
+	if err != nil {
 		return err
 	}
 
-	if apply := s.condition.applyCondition(); !apply {
-		return fmt.Errorf("(%s %s %s) -> false", s.condition.values[0], s.condition.equalityOperator, s.condition.values[1])
+	if apply := s.condition.applyCondition(values); !apply {
+		return fmt.Errorf("(%s %s %s) -> false", values[0], s.condition.equalityOperator, values[1])
 	}
 
 	return nil
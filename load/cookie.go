@@ -0,0 +1,59 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"fmt"
+	"net/http/cookiejar"
+	"net/url"
+)
+
+// resetCookies replaces the cookie jar of the worker with an empty one.
+func (ss *session) resetCookies() {
+	jar, _ := cookiejar.New(nil)
+
+	ss.client.Jar = jar
+	ss.visited = nil
+}
+
+// visit keeps the last URL requested by the worker on each scheme and host,
+// used to find its cookies. The most recent is the last one, and there is
+// one URL per host, so paths with IDs do not grow the list.
+func (ss *session) visit(u *url.URL) {
+	for i, visited := range ss.visited {
+		if visited.Scheme == u.Scheme && visited.Host == u.Host {
+			ss.visited = append(ss.visited[:i], ss.visited[i+1:]...)
+			break
+		}
+	}
+
+	ss.visited = append(ss.visited, u)
+}
+
+// cookie returns the value of the cookie sent to the last URL requested on
+// the most recently visited host that receives it.
+func (ss *session) cookie(name string) (string, error) {
+	for i := len(ss.visited) - 1; i >= 0; i-- {
+		for _, c := range ss.client.Jar.Cookies(ss.visited[i]) {
+			if c.Name == name {
+				return c.Value, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("cookie (%s) not found", name)
+}
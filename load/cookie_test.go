@@ -0,0 +1,76 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestSessionCookie(t *testing.T) {
+	jar, _ := cookiejar.New(nil)
+	ss := &session{client: &http.Client{Jar: jar}}
+
+	set := func(rawURL string, cookies ...*http.Cookie) *url.URL {
+		u, _ := url.Parse(rawURL)
+		jar.SetCookies(u, cookies)
+		ss.visit(u)
+
+		return u
+	}
+
+	set("http://a.test/login", &http.Cookie{Name: "sid", Value: "a", Path: "/"})
+	set("http://b.test/login", &http.Cookie{Name: "sid", Value: "b", Path: "/"}, &http.Cookie{Name: "admin", Value: "x", Path: "/admin"})
+
+	for i := 0; i < 1000; i++ {
+		set("http://a.test/users/" + strconv.Itoa(i))
+	}
+
+	if len(ss.visited) != 2 {
+		t.Errorf("got %d visited URLs, expected one per host", len(ss.visited))
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+		found    bool
+	}{
+		{"sid", "a", true},
+		{"admin", "", false},
+		{"missing", "", false},
+	}
+
+	for _, tt := range tests {
+		value, err := ss.cookie(tt.name)
+		if value != tt.expected || (err == nil) != tt.found {
+			t.Errorf("%s: got %q, %v, expected %q", tt.name, value, err, tt.expected)
+		}
+	}
+
+	set("http://b.test/admin/users")
+
+	if value, _ := ss.cookie("sid"); value != "b" {
+		t.Errorf("got %q, expected the cookie of the most recent host", value)
+	}
+
+	if value, _ := ss.cookie("admin"); value != "x" {
+		t.Errorf("got %q, expected the cookie of the path of the last request", value)
+	}
+}
@@ -17,12 +17,51 @@ limitations under the License.
 package load
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/gabriellasaro/load-test/metrics"
+	"regexp"
+	"strconv"
+	"time"
 )
 
+var (
+	regexStepName  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	regexStepIndex = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Cycle is parsed once per scenario and shared by all workers; the state of
+// each execution is kept in an iteration.
 type Cycle struct {
-	Steps []*Step `json:"cycle"`
+	Steps   []*Step
+	metrics *metrics.Metrics
+	names   map[string]int
+}
+
+// iteration is the state of one execution of the cycle by a worker. The
+// think times of the steps do not go beyond the deadline, when it is not zero.
+type iteration struct {
+	variables map[string]string
+	session   *session
+	system    *systemVariables
+	steps     []stepState
+	deadline  time.Time
+}
+
+type stepState struct {
+	requested  bool
+	statusCode int
+	response   *ResponseCycle
+}
+
+func (c *Cycle) newIteration(variables map[string]string, ss *session, system *systemVariables) *iteration {
+	return &iteration{
+		variables: variables,
+		session:   ss,
+		system:    system,
+		steps:     make([]stepState, len(c.Steps)),
+	}
 }
 
 func (c *Cycle) existsCycles() error {
@@ -33,30 +72,111 @@ func (c *Cycle) existsCycles() error {
 	return nil
 }
 
-func (c *Cycle) execute(variables []*Variable, loop, worker int, logLoop *logByLoop) error {
+// preload checks that the step names are unique and parses the steps.
+func (c *Cycle) preload() error {
+	c.names = make(map[string]int)
+
+	for i, step := range c.Steps {
+		if step.Name.IsEmpty() {
+			continue
+		}
+
+		if !regexStepName.MatchString(step.Name.String()) || regexStepIndex.MatchString(step.Name.String()) {
+			return fmt.Errorf("cycle[%d].name (%s) must contain only letters, digits, _ and - and cannot be a number", i, step.Name)
+		}
+
+		if j, found := c.names[step.Name.String()]; found {
+			return fmt.Errorf("cycle[%d].name (%s) is already used by cycle[%d]", i, step.Name, j)
+		}
+
+		c.names[step.Name.String()] = i
+	}
+
+	for i, step := range c.Steps {
+		if err := step.preload(i, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stepIndex returns the index of a step referenced by its index or name.
+func (c *Cycle) stepIndex(reference string) (int, error) {
+	if regexStepIndex.MatchString(reference) {
+		index, err := strconv.Atoi(reference)
+		if err == nil && index < len(c.Steps) {
+			return index, nil
+		}
+	} else if index, found := c.names[reference]; found {
+		return index, nil
+	}
+
+	return 0, fmt.Errorf("cycle[%s] not found", reference)
+}
+
+// response returns the response of a previous step of the iteration.
+func (it *iteration) response(index int) (*ResponseCycle, error) {
+	if response := it.steps[index].response; response != nil {
+		return response, nil
+	}
+
+	return nil, fmt.Errorf("the response of cycle[%d] does not exist", index)
+}
+
+// variable returns a variable extracted by the worker, a column of the data
+// sources or a variable of the test file, in this order.
+func (it *iteration) variable(key string) (string, error) {
+	if value, found := it.session.variables[key]; found {
+		return value, nil
+	}
+
+	if value, found := it.session.data[key]; found {
+		return value, nil
+	}
+
+	if value, found := it.variables[key]; found {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("variable (%s) not found", key)
+}
+
+func (c *Cycle) execute(ctx context.Context, it *iteration, loop, worker int, logLoop *logByLoop) error {
 	if err := c.existsCycles(); err != nil {
 		return err
 	}
 
-	logCycle := fmt.Sprintf("----------------\n\nWORKER [%d] | STEPS TO RUN: %d [0-%d]\n", worker, len(c.Steps), len(c.Steps)-1)
+	logCycle := fmt.Sprintf("----------------\n\nWORKER [%d] | LOOP [%d] | STEPS TO RUN: %d [0-%d]\n", worker, loop, len(c.Steps), len(c.Steps)-1)
 
 	for i, step := range c.Steps {
-		if err := c.Steps[i].preload(i); err != nil {
-			logCycle += step.responseDataToLog(step.index, err)
+		state := &it.steps[i]
+
+		if ctx.Err() != nil {
+			logCycle += step.responseDataToLog(state, errInterrupted)
 			logLoop.sendDataToHistory(logCycle)
+			c.metrics.AddInterrupted()
 
-			return err
+			return errInterrupted
 		}
 
-		err := step.execute(variables, &c.Steps)
-		logCycle += step.responseDataToLog(step.index, err)
+		err := step.execute(ctx, it)
+		logCycle += step.responseDataToLog(state, err)
+		step.addRequest(c.metrics, state)
 		if err != nil {
 			logLoop.sendDataToHistory(logCycle)
 
+			if kind := errorKind(ctx, err); kind == errorKindInterrupted {
+				c.metrics.AddInterrupted()
+			} else {
+				c.metrics.AddError(i, kind)
+			}
+
 			return err
 		}
 
-		step.addDuration(loop)
+		step.addDuration(c.metrics, loop, state)
+		step.ThinkTime.wait(ctx, it.deadline)
 	}
 
 	logLoop.sendDataToHistory(logCycle)
@@ -0,0 +1,324 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabriellasaro/load-test/jsonquery"
+	"github.com/gabriellasaro/load-test/types"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	dataFormatCSV   = "CSV"
+	dataFormatJSONL = "JSONL"
+)
+
+const (
+	dataModeSequential = "SEQUENTIAL"
+	dataModeRandom     = "RANDOM"
+	dataModeUnique     = "UNIQUE"
+)
+
+const (
+	exhaustionRecycle = "RECYCLE"
+	exhaustionStop    = "STOP"
+	exhaustionError   = "ERROR"
+)
+
+// errDataStop stops the test when a data source with on_exhaustion "stop"
+// has no more rows.
+var errDataStop = errors.New("data exhausted")
+
+// Data is a file of rows (CSV with a header row or JSON lines) whose columns
+// are available as {%VAR:column:ENDVAR%}:
+//
+//	sequential: each iteration takes the next row, shared by all workers
+//	random:     each iteration takes a random row
+//	unique:     each worker takes one row and keeps it
+type Data struct {
+	File         string    `json:"file"`
+	Format       types.Str `json:"format,omitempty"`
+	Mode         types.Str `json:"mode,omitempty"`
+	OnExhaustion types.Str `json:"on_exhaustion,omitempty"`
+	Delimiter    string    `json:"delimiter,omitempty"`
+	rows         []map[string]string
+	mu           sync.Mutex
+	cursor       int
+	assigned     map[*session]map[string]string
+}
+
+func (d *Data) format() string {
+	format := d.Format.TrimSpace().ToUpper()
+	if !format.IsEmpty() {
+		return format.String()
+	}
+
+	switch strings.ToLower(filepath.Ext(d.File)) {
+	case ".jsonl", ".ndjson":
+		return dataFormatJSONL
+	default:
+		return dataFormatCSV
+	}
+}
+
+func (d *Data) mode() string {
+	mode := d.Mode.TrimSpace().ToUpper()
+	if mode.IsEmpty() {
+		return dataModeSequential
+	}
+
+	return mode.String()
+}
+
+// onExhaustion defaults to recycle, except in the unique mode, where sharing
+// rows between workers must be explicit.
+func (d *Data) onExhaustion() string {
+	onExhaustion := d.OnExhaustion.TrimSpace().ToUpper()
+	if onExhaustion.IsEmpty() && d.mode() == dataModeUnique {
+		return exhaustionError
+	}
+
+	if onExhaustion.IsEmpty() {
+		return exhaustionRecycle
+	}
+
+	return onExhaustion.String()
+}
+
+func (d *Data) preload() error {
+	if strings.TrimSpace(d.File) == "" {
+		return errors.New("file cannot be empty")
+	}
+
+	switch d.mode() {
+	case dataModeSequential, dataModeRandom, dataModeUnique:
+	default:
+		return fmt.Errorf("mode (%s) is not valid", d.Mode)
+	}
+
+	switch d.onExhaustion() {
+	case exhaustionRecycle, exhaustionStop, exhaustionError:
+	default:
+		return fmt.Errorf("on_exhaustion (%s) is not valid", d.OnExhaustion)
+	}
+
+	content, err := os.ReadFile(d.File)
+	if err != nil {
+		return err
+	}
+
+	switch d.format() {
+	case dataFormatCSV:
+		err = d.readCSV(content)
+	case dataFormatJSONL:
+		err = d.readJSONL(content)
+	default:
+		return fmt.Errorf("format (%s) is not valid", d.Format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", d.File, err.Error())
+	}
+
+	if len(d.rows) == 0 {
+		return fmt.Errorf("%s: the file has no rows", d.File)
+	}
+
+	d.assigned = make(map[*session]map[string]string)
+
+	return nil
+}
+
+func newDataRow(columns []string, values []string) map[string]string {
+	row := make(map[string]string, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+
+	return row
+}
+
+func (d *Data) readCSV(content []byte) error {
+	reader := csv.NewReader(bytes.NewReader(content))
+
+	if d.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(d.Delimiter)
+		if size != len(d.Delimiter) {
+			return fmt.Errorf("delimiter (%s) must be a single character", d.Delimiter)
+		}
+
+		reader.Comma = delimiter
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	columns := records[0]
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+		if columns[i] == "" {
+			return fmt.Errorf("the column %d of the header is empty", i+1)
+		}
+	}
+
+	for _, record := range records[1:] {
+		d.rows = append(d.rows, newDataRow(columns, record))
+	}
+
+	return nil
+}
+
+func (d *Data) readJSONL(content []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var object map[string]interface{}
+
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			return fmt.Errorf("line %d: %s", line, err.Error())
+		}
+
+		columns := make([]string, 0, len(object))
+		for column := range object {
+			columns = append(columns, column)
+		}
+
+		sort.Strings(columns)
+
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			value, err := jsonquery.Format(object[column])
+			if err != nil {
+				return fmt.Errorf("line %d: %s", line, err.Error())
+			}
+
+			values[i] = value
+		}
+
+		d.rows = append(d.rows, newDataRow(columns, values))
+	}
+
+	return scanner.Err()
+}
+
+// exhausted applies on_exhaustion when there are no more rows. It returns the
+// index of the row to use when recycling.
+func (d *Data) exhausted(i int) (int, error) {
+	switch d.onExhaustion() {
+	case exhaustionStop:
+		return 0, errDataStop
+	case exhaustionError:
+		return 0, fmt.Errorf("the data (%s) has no more rows", d.File)
+	default:
+		return i % len(d.rows), nil
+	}
+}
+
+// row returns the row of the next iteration of the worker.
+func (d *Data) row(ss *session) (map[string]string, error) {
+	if d.mode() == dataModeRandom {
+		return d.rows[rand.Intn(len(d.rows))], nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if row, found := d.assigned[ss]; found {
+		return row, nil
+	}
+
+	i := d.cursor
+	if i >= len(d.rows) {
+		var err error
+
+		if i, err = d.exhausted(i); err != nil {
+			return nil, err
+		}
+	}
+
+	d.cursor++
+
+	if d.mode() == dataModeUnique {
+		d.assigned[ss] = d.rows[i]
+	}
+
+	return d.rows[i], nil
+}
+
+func (lt *DataTest) preloadData() error {
+	for i, d := range lt.Data {
+		if err := d.preload(); err != nil {
+			return fmt.Errorf("data[%d]: %s", i, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// nextData sets the rows of the data sources for the next iteration of the
+// worker.
+func (lt *DataTest) nextData(ss *session) error {
+	ss.data = make(map[string]string)
+
+	for _, d := range lt.Data {
+		row, err := d.row(ss)
+		if err == errDataStop && atomic.CompareAndSwapInt32(&lt.dataStopped, 0, 1) {
+			lt.sendDataToHistory(fmt.Sprintf("\nTEST STOPPED: DATA (%s) EXHAUSTED", d.File), true)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for column, value := range row {
+			ss.data[column] = value
+		}
+	}
+
+	return nil
+}
+
+// dataExhausted reports whether a data source stopped the test.
+func (lt *DataTest) dataExhausted() bool {
+	return atomic.LoadInt32(&lt.dataStopped) == 1
+}
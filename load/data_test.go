@@ -0,0 +1,258 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"github.com/gabriellasaro/load-test/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newData writes the content to a file named name and preloads the data,
+// or a default one when d is nil.
+func newData(t *testing.T, name, content string, d *Data) (*Data, error) {
+	t.Helper()
+
+	if d == nil {
+		d = new(Data)
+	}
+
+	d.File = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(d.File, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, d.preload()
+}
+
+func TestDataReaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		data     *Data
+		expected []map[string]string
+	}{
+		{
+			name:    "csv",
+			file:    "users.csv",
+			content: " user , pass\nana,1\n\"bob, jr\",\"a\"\"b\"\n",
+			expected: []map[string]string{
+				{"user": "ana", "pass": "1"},
+				{"user": "bob, jr", "pass": `a"b`},
+			},
+		},
+		{
+			name:     "csv with delimiter",
+			file:     "users.csv",
+			content:  "user;pass\nana;1,5\n",
+			data:     &Data{Delimiter: ";"},
+			expected: []map[string]string{{"user": "ana", "pass": "1,5"}},
+		},
+		{
+			name:     "csv with a multibyte delimiter",
+			file:     "users.txt",
+			content:  "user§pass\nana§1\n",
+			data:     &Data{Delimiter: "§"},
+			expected: []map[string]string{{"user": "ana", "pass": "1"}},
+		},
+		{
+			name:    "jsonl",
+			file:    "users.jsonl",
+			content: "{\"user\": \"ana\", \"id\": 12345678901234567890, \"tags\": [\"a\"], \"admin\": true, \"note\": null}\n\n{\"user\": \"bob\", \"id\": 1.50}\n",
+			expected: []map[string]string{
+				{"user": "ana", "id": "12345678901234567890", "tags": `["a"]`, "admin": "true", "note": "null"},
+				{"user": "bob", "id": "1.50"},
+			},
+		},
+		{
+			name:     "ndjson",
+			file:     "users.ndjson",
+			content:  `{"user": "ana"}`,
+			expected: []map[string]string{{"user": "ana"}},
+		},
+		{
+			name:     "format over the extension",
+			file:     "users.csv",
+			content:  `{"user": "ana"}`,
+			data:     &Data{Format: types.Str(" jsonl ")},
+			expected: []map[string]string{{"user": "ana"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newData(t, tt.file, tt.content, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(d.rows, tt.expected) {
+				t.Errorf("got %v, expected %v", d.rows, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDataErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		data    *Data
+		err     string
+	}{
+		{"empty column", "a.csv", "user,\nana,1\n", new(Data), "the column 2 of the header is empty"},
+		{"ragged row", "a.csv", "user,pass\nana\n", new(Data), "wrong number of fields"},
+		{"long delimiter", "a.csv", "user\nana\n", &Data{Delimiter: ";;"}, "must be a single character"},
+		{"only header", "a.csv", "user,pass\n", new(Data), "the file has no rows"},
+		{"empty file", "a.jsonl", "\n\n", new(Data), "the file has no rows"},
+		{"invalid line", "a.jsonl", "{\"user\": \"ana\"}\n{\"user\": }\n", new(Data), "line 2:"},
+		{"not an object", "a.jsonl", "[1, 2]\n", new(Data), "line 1:"},
+		{"format", "a.csv", "user\nana\n", &Data{Format: types.Str("xml")}, "format (xml) is not valid"},
+		{"mode", "a.csv", "user\nana\n", &Data{Mode: types.Str("shuffle")}, "mode (shuffle) is not valid"},
+		{"on_exhaustion", "a.csv", "user\nana\n", &Data{OnExhaustion: types.Str("wait")}, "on_exhaustion (wait) is not valid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newData(t, tt.file, tt.content, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, expected an error with %q", err, tt.err)
+			}
+		})
+	}
+
+	if err := (&Data{File: filepath.Join(t.TempDir(), "missing.csv")}).preload(); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	if err := new(Data).preload(); err == nil || err.Error() != "file cannot be empty" {
+		t.Errorf("got %v, expected the file to be required", err)
+	}
+}
+
+func TestDataRow(t *testing.T) {
+	const users = "user\na\nb\nc\n"
+
+	// each step is a call of row by the session of the worker; "!" is an
+	// error and "stop" is errDataStop.
+	tests := []struct {
+		name     string
+		mode     string
+		on       string
+		workers  []int
+		expected []string
+	}{
+		{"sequential recycles", "", "", []int{1, 2, 1, 2, 1}, []string{"a", "b", "c", "a", "b"}},
+		{"sequential stops", "sequential", "stop", []int{1, 1, 1, 1}, []string{"a", "b", "c", "stop"}},
+		{"sequential errors", "sequential", "error", []int{1, 2, 3, 1}, []string{"a", "b", "c", "!"}},
+		{"unique keeps the row of the worker", "unique", "", []int{1, 2, 1, 3, 2}, []string{"a", "b", "a", "c", "b"}},
+		{"unique errors by default", "unique", "", []int{1, 2, 3, 4, 4, 1}, []string{"a", "b", "c", "!", "!", "a"}},
+		{"unique recycles", "unique", "recycle", []int{1, 2, 3, 4, 5, 4}, []string{"a", "b", "c", "a", "b", "a"}},
+		{"unique stops", "unique", "stop", []int{1, 2, 3, 4}, []string{"a", "b", "c", "stop"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := newData(t, "users.csv", users, &Data{Mode: types.Str(tt.mode), OnExhaustion: types.Str(tt.on)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sessions := make(map[int]*session)
+
+			for i, worker := range tt.workers {
+				if sessions[worker] == nil {
+					sessions[worker] = &session{worker: worker}
+				}
+
+				row, err := d.row(sessions[worker])
+
+				var got string
+
+				switch {
+				case err == errDataStop:
+					got = "stop"
+				case err != nil:
+					got = "!"
+				default:
+					got = row["user"]
+				}
+
+				if got != tt.expected[i] {
+					t.Errorf("call %d by worker %d: got %s, expected %s", i+1, worker, got, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDataRowRandom(t *testing.T) {
+	d, err := newData(t, "users.csv", "user\na\nb\nc\n", &Data{Mode: types.Str("random"), OnExhaustion: types.Str("error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := new(session)
+	seen := make(map[string]bool)
+
+	for i := 0; i < 200; i++ {
+		row, err := d.row(ss)
+		if err != nil {
+			t.Fatalf("random rows are never exhausted: %s", err.Error())
+		}
+
+		seen[row["user"]] = true
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("got the rows %v, expected all of them", seen)
+	}
+}
+
+func TestNextDataStops(t *testing.T) {
+	lt := new(DataTest)
+
+	users, err := newData(t, "users.csv", "user\na\n", &Data{OnExhaustion: types.Str("stop")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := newData(t, "ids.jsonl", `{"id": 7, "user": "x"}`, new(Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lt.Data = []*Data{users, ids}
+	ss := new(session)
+
+	if err := lt.nextData(ss); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string]string{"user": "x", "id": "7"}; !reflect.DeepEqual(ss.data, expected) {
+		t.Errorf("got %v, expected the columns of the sources in order", ss.data)
+	}
+
+	if err := lt.nextData(ss); err != errDataStop || !lt.dataExhausted() {
+		t.Errorf("got %v, expected the test to stop", err)
+	}
+}
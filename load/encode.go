@@ -0,0 +1,155 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gabriellasaro/load-test/template"
+	"net/url"
+	"strings"
+)
+
+// encoder encodes the value of a tag for the place where it is inserted.
+type encoder func(value string) string
+
+// filters are the encoders chosen in the tag, as in {%VAR:name:ENDVAR|raw%}.
+var filters = map[string]encoder{
+	"raw":    encodeRaw,
+	"json":   encodeJSON,
+	"query":  url.QueryEscape,
+	"path":   url.PathEscape,
+	"header": encodeHeader,
+}
+
+func encodeRaw(value string) string {
+	return value
+}
+
+// encodeJSON escapes the value for a JSON string, without the quotes.
+func encodeJSON(value string) string {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(buffer.String()), `"`), `"`)
+}
+
+// encodeHeader removes the control characters, except tab, which would end
+// the header or be rejected by the client.
+func encodeHeader(value string) string {
+	return strings.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\t') || r == 0x7f {
+			return -1
+		}
+
+		return r
+	}, value)
+}
+
+// encodedByFunction reports whether the tag calls urlEncode, whose value is
+// already encoded.
+func encodedByFunction(tag *template.Tag) bool {
+	name, _, _ := tagName(tag)
+
+	return name == tagFn && len(tag.Fields) > 1 && functionName(tag.Fields[1].String()) == "urlEncode"
+}
+
+// encodeURL sets the encoders of the tags of the url: the tags of the path
+// are encoded as path segments and the tags of the query and the fragment as
+// query values. A tag at the beginning, in the scheme or in the host is the
+// base of the url and is not encoded, nor is the result of urlEncode.
+func (s *Step) encodeURL(t *template.Template) {
+	var before strings.Builder
+
+	t.Scan(func(text string, tag *template.Tag) {
+		if tag == nil {
+			before.WriteString(text)
+			return
+		}
+
+		prefix := before.String()
+		before.WriteString("tag") // the value is not known yet
+
+		if encodedByFunction(tag) {
+			return
+		}
+
+		if strings.ContainsAny(prefix, "?#") {
+			s.encoders[tag] = url.QueryEscape
+			return
+		}
+
+		if i := strings.Index(prefix, "://"); i >= 0 {
+			prefix = prefix[i+len("://"):]
+		}
+
+		if strings.Contains(prefix, "/") {
+			s.encoders[tag] = url.PathEscape
+		}
+	})
+}
+
+// encodeHeader sets the encoder of the tags of a header.
+func (s *Step) encodeHeader(t *template.Template) {
+	t.Scan(func(_ string, tag *template.Tag) {
+		if tag != nil {
+			s.encoders[tag] = encodeHeader
+		}
+	})
+}
+
+// encodeJSONBody sets the encoder of the tags inside the strings of a JSON
+// body. The tags outside strings, such as numbers, are not encoded.
+func (s *Step) encodeJSONBody(t *template.Template) {
+	inString := false
+
+	t.Scan(func(text string, tag *template.Tag) {
+		if tag != nil {
+			if inString {
+				s.encoders[tag] = encodeJSON
+			}
+
+			return
+		}
+
+		for i := 0; i < len(text); i++ {
+			switch {
+			case inString && text[i] == '\\':
+				i++
+			case text[i] == '"':
+				inString = !inString
+			}
+		}
+	})
+}
+
+// encode applies the filter of the tag or, without a filter, the encoder of
+// the place where the tag is inserted.
+func (s *Step) encode(tag *template.Tag, value string) string {
+	if tag.Filter != "" {
+		return filters[tag.Filter](value)
+	}
+
+	if encode, found := s.encoders[tag]; found {
+		return encode(value)
+	}
+
+	return value
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"github.com/gabriellasaro/load-test/template"
+	"testing"
+)
+
+func TestEncodeURL(t *testing.T) {
+	s := &Step{encoders: make(map[*template.Tag]encoder)}
+
+	url, err := template.Parse("{%VAR:base:ENDVAR%}/users/{%VAR:id:ENDVAR%}?q={%VAR:q:ENDVAR%}&u={%FN:urlEncode({%VAR:q:ENDVAR%})%}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.encodeURL(url)
+
+	value, err := url.Execute(func(tag *template.Tag, fields []string) (string, error) {
+		values := map[string]string{"base": "http://host", "id": "a/b", "q": "x y&z"}
+
+		value := values[fields[1]]
+
+		if name, _, _ := tagName(tag); name == tagFn {
+			var err error
+			if value, err = callFunction(joinFields(fields[1:])); err != nil {
+				return "", err
+			}
+		}
+
+		return s.encode(tag, value), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "http://host/users/a%2Fb?q=x+y%26z&u=x+y%26z"; value != expected {
+		t.Errorf("got %s, expected %s", value, expected)
+	}
+}
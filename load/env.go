@@ -18,13 +18,9 @@ package load
 
 import (
 	"fmt"
-	"github.com/gabriellasaro/load-test/types"
 	"os"
-	"regexp"
 )
 
-var regexVarEnv = regexp.MustCompile(`{%ENV:([^ ]+):ENDENV%}`)
-
 func lookupEnv(key string) (string, error) {
 	value, ok := os.LookupEnv(key)
 	if !ok {
@@ -33,7 +29,3 @@ func lookupEnv(key string) (string, error) {
 
 	return value, nil
 }
-
-func getEnvironmentVariables(data string) ([]*types.Variable, error) {
-	return types.GetVariables(regexVarEnv, data, lookupEnv)
-}
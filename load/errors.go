@@ -0,0 +1,76 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+const (
+	errorKindTimeout           = "timeout"
+	errorKindConnectionRefused = "connection_refused"
+	errorKindConnectionReset   = "connection_reset"
+	errorKindDNS               = "dns"
+	errorKindConditionFailed   = "condition_failed"
+	errorKindInterrupted       = "interrupted"
+	errorKindOther             = "other"
+)
+
+var errInterrupted = errors.New("cycle interrupted by the end of the test")
+
+type conditionError struct {
+	condition string
+	err       error
+}
+
+func (e *conditionError) Error() string {
+	return fmt.Sprintf("condition (%s) is not satisfied: %s", e.condition, e.err.Error())
+}
+
+func (e *conditionError) Unwrap() error {
+	return e.err
+}
+
+// errorKind groups the errors of the steps for the report.
+func errorKind(ctx context.Context, err error) string {
+	var (
+		condErr *conditionError
+		dnsErr  *net.DNSError
+		netErr  net.Error
+	)
+
+	switch {
+	case errors.As(err, &condErr):
+		return errorKindConditionFailed
+	case errors.Is(err, errInterrupted), ctx.Err() != nil && errors.Is(err, ctx.Err()):
+		return errorKindInterrupted
+	case errors.As(err, &dnsErr):
+		return errorKindDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errorKindConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return errorKindConnectionReset
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorKindTimeout
+	default:
+		return errorKindOther
+	}
+}
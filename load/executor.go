@@ -0,0 +1,376 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gabriellasaro/load-test/types"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	executorLockstep    = "LOCKSTEP"
+	executorIndependent = "INDEPENDENT"
+	executorArrivalRate = "ARRIVAL_RATE"
+	executorRamping     = "RAMPING"
+)
+
+// rampingIdle is how long a worker above the current target waits before
+// checking the target again.
+const rampingIdle = 100 * time.Millisecond
+
+type Stage struct {
+	Duration types.Duration `json:"duration"`
+	Target   int            `json:"target"`
+}
+
+// arrivals counts the cycles scheduled by the arrival rate executor.
+type arrivals struct {
+	started int
+	dropped int
+}
+
+func (sc *Scenario) executor() string {
+	executor := sc.Executor.TrimSpace().ToUpper()
+	if executor.IsEmpty() {
+		if len(sc.Stages) > 0 {
+			return executorRamping
+		}
+
+		return executorLockstep
+	}
+
+	return executor.String()
+}
+
+func (sc *Scenario) validateExecutor() error {
+	if len(sc.Stages) > 0 && sc.executor() != executorRamping {
+		return errors.New("\"stages\" can only be used with the ramping executor")
+	}
+
+	switch sc.executor() {
+	case executorLockstep, executorIndependent:
+		return nil
+	case executorArrivalRate:
+		if sc.Pacing > 0 {
+			return errors.New("\"pacing\" cannot be used with the arrival_rate executor")
+		}
+
+		if sc.Rate <= 0 {
+			return errors.New("\"rate\" must be greater than zero for the arrival_rate executor")
+		}
+
+		if sc.MaxInFlight < 0 {
+			return errors.New("\"max_in_flight\" must be greater than zero")
+		}
+
+		return nil
+	case executorRamping:
+		return sc.validateStages()
+	default:
+		return fmt.Errorf("executor (%s) is not valid", sc.Executor)
+	}
+}
+
+func (sc *Scenario) executeCycle(ctx context.Context, loop, worker int, logLoop *logByLoop) {
+	start := time.Now()
+	defer sc.pace(ctx, start)
+
+	ss := sc.sessions.get(worker)
+	if sc.ResetCookies {
+		ss.resetCookies()
+	}
+
+	it := sc.cycle.newIteration(sc.test.variables, ss, sc.newSystemVariables(ss, loop))
+	it.deadline = sc.waitDeadline()
+
+	err := sc.test.nextData(ss)
+	if err == errDataStop {
+		return
+	}
+
+	if err != nil {
+		sc.metrics.AddDataError()
+	} else {
+		err = sc.cycle.execute(ctx, it, loop, worker, logLoop)
+	}
+
+	logTime := time.Now().Format("01-02-2006 15:04:05")
+
+	if err != nil {
+		sc.test.sendDataToHistory(
+			fmt.Sprintf("%s: %sLOOP: %d | WORKER: %d | ERROR: %q", logTime, sc.logPrefix(), loop, worker, err),
+			true,
+		)
+	} else {
+		sc.test.sendDataToHistory(
+			fmt.Sprintf("%s: %sLOOP: %d | WORKER: %d | SUCCESS", logTime, sc.logPrefix(), loop, worker),
+			true,
+		)
+	}
+}
+
+// waitDeadline is the limit of the think times and the pacing: the end of the
+// duration, or zero without a duration.
+func (sc *Scenario) waitDeadline() time.Time {
+	if sc.hasDuration() {
+		return sc.deadline
+	}
+
+	return time.Time{}
+}
+
+// pace waits the think time of the cycle and pads the iteration to the pacing.
+// The wait never goes beyond the end of the duration.
+func (sc *Scenario) pace(ctx context.Context, start time.Time) {
+	if sc.ThinkTime == nil && sc.Pacing <= 0 {
+		return
+	}
+
+	sc.ThinkTime.wait(ctx, sc.waitDeadline())
+	pause(ctx, boundedBy(sc.Pacing.Duration()-time.Since(start), sc.waitDeadline()))
+}
+
+// runLockstep starts every loop with all workers at the same time and waits
+// for all of them before starting the next loop.
+func (sc *Scenario) runLockstep(ctx context.Context) error {
+	loop := 1
+	for {
+		var wgLoop sync.WaitGroup
+
+		wgLoop.Add(sc.workersPerLoop())
+
+		sc.test.sendDataToHistory(fmt.Sprintf("\n%sLOOP %d\n", sc.logPrefix(), loop), true)
+
+		logLoop, err := sc.startLogForLoop(loop)
+		if err != nil {
+			return err
+		}
+
+		for w := 1; w <= sc.workersPerLoop(); w++ {
+			go func(worker int) {
+				defer wgLoop.Done()
+
+				sc.executeCycle(ctx, loop, worker, logLoop)
+			}(w)
+		}
+
+		wgLoop.Wait()
+		logLoop.waitHistory()
+
+		if sc.finished(loop) || ctx.Err() != nil {
+			break
+		}
+
+		loop += 1
+	}
+
+	return nil
+}
+
+// runIndependent lets each worker run its own loops back-to-back, without
+// waiting for the other workers. The loop is a per-worker iteration counter.
+func (sc *Scenario) runIndependent(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	wg.Add(sc.workersPerLoop())
+
+	for w := 1; w <= sc.workersPerLoop(); w++ {
+		go func(worker int) {
+			defer wg.Done()
+
+			logWorker := sc.startLogForWorker(worker)
+			defer logWorker.waitHistory()
+
+			for loop := 1; ; loop++ {
+				sc.executeCycle(ctx, loop, worker, logWorker)
+
+				if sc.finished(loop) || ctx.Err() != nil {
+					return
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (sc *Scenario) maxInFlight() int {
+	if sc.MaxInFlight <= 0 {
+		return sc.Rate
+	}
+
+	return sc.MaxInFlight
+}
+
+// runArrivalRate starts "rate" cycles per second regardless of how long the
+// previous ones take. A cycle is dropped when "max_in_flight" cycles are
+// already running at the moment it was scheduled.
+func (sc *Scenario) runArrivalRate(ctx context.Context) error {
+	workers := make(chan int, sc.maxInFlight())
+	logWorkers := make([]*logByLoop, sc.maxInFlight()+1)
+
+	for w := 1; w <= sc.maxInFlight(); w++ {
+		workers <- w
+		logWorkers[w] = sc.startLogForWorker(w)
+	}
+
+	var wg sync.WaitGroup
+
+	interval := time.Second / time.Duration(sc.Rate)
+	start := time.Now()
+
+	for loop := 1; ; loop++ {
+		scheduled := start.Add(time.Duration(loop-1) * interval)
+		if sc.hasDuration() && !scheduled.Before(sc.deadline) || sc.test.dataExhausted() {
+			break
+		}
+
+		pause(ctx, time.Until(scheduled))
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case worker := <-workers:
+			sc.arrivals.started++
+
+			wg.Add(1)
+
+			go func(loop, worker int) {
+				defer func() {
+					workers <- worker
+					wg.Done()
+				}()
+
+				sc.executeCycle(ctx, loop, worker, logWorkers[worker])
+			}(loop, worker)
+		default:
+			sc.arrivals.dropped++
+		}
+
+		if !sc.hasDuration() && sc.finished(loop) {
+			break
+		}
+	}
+
+	wg.Wait()
+
+	for _, logWorker := range logWorkers {
+		logWorker.waitHistory()
+	}
+
+	return nil
+}
+
+// validateStages also sets the duration of the test as the sum of the stages.
+func (sc *Scenario) validateStages() error {
+	if len(sc.Stages) == 0 {
+		return errors.New("\"stages\" must be informed for the ramping executor")
+	}
+
+	if sc.hasDuration() {
+		return errors.New("\"duration\" cannot be used with \"stages\"")
+	}
+
+	for i, stage := range sc.Stages {
+		if stage.Duration <= 0 {
+			return fmt.Errorf("stages[%d].duration must be greater than zero", i)
+		}
+
+		if stage.Target < 0 {
+			return fmt.Errorf("stages[%d].target cannot be negative", i)
+		}
+
+		sc.Duration += stage.Duration
+	}
+
+	return nil
+}
+
+func (sc *Scenario) maxTarget() int {
+	target := sc.Parallel
+	for _, stage := range sc.Stages {
+		if stage.Target > target {
+			target = stage.Target
+		}
+	}
+
+	return target
+}
+
+// targetAt returns the number of active workers after the elapsed time,
+// interpolating linearly from the target of the previous stage. The first
+// stage starts from "parallel".
+func (sc *Scenario) targetAt(elapsed time.Duration) int {
+	from := float64(sc.Parallel)
+
+	for _, stage := range sc.Stages {
+		to := float64(stage.Target)
+		if elapsed < stage.Duration.Duration() {
+			progress := float64(elapsed) / float64(stage.Duration)
+
+			return int(math.Round(from + (to-from)*progress))
+		}
+
+		elapsed -= stage.Duration.Duration()
+		from = to
+	}
+
+	return int(from)
+}
+
+// runRamping keeps active only the workers whose number is within the target
+// of the current stage. Workers above the target finish their cycle and wait.
+func (sc *Scenario) runRamping(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	wg.Add(sc.maxTarget())
+
+	start := time.Now()
+
+	for w := 1; w <= sc.maxTarget(); w++ {
+		go func(worker int) {
+			defer wg.Done()
+
+			logWorker := sc.startLogForWorker(worker)
+			defer logWorker.waitHistory()
+
+			loop := 1
+			for time.Now().Before(sc.deadline) && ctx.Err() == nil && !sc.test.dataExhausted() {
+				if worker > sc.targetAt(time.Since(start)) {
+					time.Sleep(rampingIdle)
+					continue
+				}
+
+				sc.executeCycle(ctx, loop, worker, logWorker)
+				loop++
+			}
+		}(w)
+	}
+
+	wg.Wait()
+
+	return nil
+}
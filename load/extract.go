@@ -0,0 +1,211 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gabriellasaro/load-test/jsonquery"
+	"github.com/gabriellasaro/load-test/xpath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	extractJSON     = "json"
+	extractHeader   = "header"
+	extractRegex    = "regex"
+	extractBound    = "bound"
+	extractXPath    = "xpath"
+	extractCookie   = "cookie"
+	extractStatus   = "status"
+	extractURL      = "url"
+	extractSize     = "size"
+	extractDuration = "duration"
+)
+
+var regexGroup = regexp.MustCompile(`:[0-9]+$`)
+
+// Extraction reads a value of the response into a variable of the worker.
+// It can be the source ("json:$.data.token") or an object with a default
+// value used when the extraction fails:
+//
+//	{"from": "header:Location", "default": "/"}
+type Extraction struct {
+	From     string  `json:"from"`
+	Default  *string `json:"default,omitempty"`
+	compiled interface{}
+}
+
+func (e *Extraction) UnmarshalJSON(data []byte) error {
+	if trimmed := strings.TrimSpace(string(data)); !strings.HasPrefix(trimmed, "{") {
+		return json.Unmarshal(data, &e.From)
+	}
+
+	type extraction Extraction
+
+	return json.Unmarshal(data, (*extraction)(e))
+}
+
+func (e *Extraction) source() (string, string) {
+	kind, argument, _ := strings.Cut(strings.TrimSpace(e.From), ":")
+
+	return strings.ToLower(kind), argument
+}
+
+// regexWithGroup adds the default group to a pattern without one: the first
+// group of the pattern, or the whole match.
+func regexWithGroup(argument string) (string, error) {
+	if regexGroup.MatchString(argument) {
+		return argument, nil
+	}
+
+	re, err := regexp.Compile(argument)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex (%s): %s", argument, err.Error())
+	}
+
+	if re.NumSubexp() > 0 {
+		return argument + ":1", nil
+	}
+
+	return argument + ":0", nil
+}
+
+// validate checks the source and compiles its query, pattern or xpath.
+func (e *Extraction) validate() error {
+	kind, argument := e.source()
+
+	switch kind {
+	case extractJSON:
+		query, err := jsonquery.Compile(argument)
+		e.compiled = query
+		return err
+	case extractXPath:
+		expression, err := xpath.Compile(argument)
+		e.compiled = expression
+		return err
+	case extractRegex:
+		variable, err := regexWithGroup(argument)
+		if err != nil {
+			return err
+		}
+
+		match, err := compileRegex(variable)
+		e.compiled = match
+		return err
+	case extractBound:
+		_, _, err := splitBoundaries(argument)
+		return err
+	case extractHeader, extractCookie:
+		if argument == "" {
+			return fmt.Errorf("the source (%s) requires a name", kind)
+		}
+	case extractStatus, extractURL, extractSize, extractDuration:
+	default:
+		return fmt.Errorf("the source (%s) is not valid", e.From)
+	}
+
+	return nil
+}
+
+// value returns the extracted value, or the default if the extraction fails.
+func (e *Extraction) value(r *ResponseCycle, ss *session) (string, error) {
+	value, err := e.extract(r, ss)
+	if err != nil && e.Default != nil {
+		return *e.Default, nil
+	}
+
+	return value, err
+}
+
+func (e *Extraction) extract(r *ResponseCycle, ss *session) (string, error) {
+	kind, argument := e.source()
+
+	switch kind {
+	case extractJSON:
+		return r.getValueInResponseByQuery(e.compiled.(*jsonquery.Query))
+	case extractXPath:
+		return r.evaluateXPath(e.compiled.(*xpath.Expression))
+	case extractRegex:
+		return r.matchRegex(e.compiled.(*regexGroupMatch))
+	case extractBound:
+		left, right, err := splitBoundaries(argument)
+		if err != nil {
+			return "", err
+		}
+
+		return r.getValueByBoundaries(left, right)
+	case extractCookie:
+		return ss.cookie(argument)
+	case extractHeader:
+		return r.getValueInResponseVariable("HEADER:" + argument)
+	case extractStatus:
+		return r.getValueInResponseVariable("STATUS_CODE")
+	default:
+		return r.getValueInResponseVariable(strings.ToUpper(kind))
+	}
+}
+
+func (s *Step) validateExtract() error {
+	for name, extraction := range s.Extract {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("cycle[%d].extract has an empty variable name", s.index)
+		}
+
+		if err := extraction.validate(); err != nil {
+			return fmt.Errorf("cycle[%d].extract[%s]: %s", s.index, name, err.Error())
+		}
+
+		s.Extract[name] = extraction
+	}
+
+	return nil
+}
+
+// extract keeps the values of the extract block in the session of the worker.
+func (s *Step) extract(response *ResponseCycle, ss *session) error {
+	names := make([]string, 0, len(s.Extract))
+	for name := range s.Extract {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		extraction := s.Extract[name]
+
+		value, err := extraction.value(response, ss)
+		if err != nil {
+			return fmt.Errorf("cycle[%d].extract[%s]: %s", s.index, name, err.Error())
+		}
+
+		ss.setVariable(name, value)
+	}
+
+	return nil
+}
+
+// setVariable keeps a variable of the worker, available as {%VAR:name:ENDVAR%}.
+func (ss *session) setVariable(name, value string) {
+	if ss.variables == nil {
+		ss.variables = make(map[string]string)
+	}
+
+	ss.variables[name] = value
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const randStringLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// maxRandStringLength limits the texts of randString, which are built for
+// each request.
+const maxRandStringLength = 1 << 20
+
+// templateFunction receives the arguments of {%FN:name(arguments)%}. The
+// result of the functions with json is a JSON value (number, boolean...),
+// which keeps its type in body_json.
+type templateFunction struct {
+	arguments int
+	optional  bool
+	json      bool
+	call      func(args []string) (string, error)
+}
+
+// templateFunctions with one argument receive the whole text between the
+// parentheses, so it can contain commas.
+var templateFunctions = map[string]templateFunction{
+	"uuid":       {arguments: 0, call: fnUUID},
+	"randInt":    {arguments: 2, json: true, call: fnRandInt},
+	"randString": {arguments: 1, call: fnRandString},
+	"now":        {arguments: 1, optional: true, call: fnNow},
+	"base64":     {arguments: 1, call: fnBase64},
+	"sha256":     {arguments: 1, call: fnSHA256},
+	"urlEncode":  {arguments: 1, call: fnURLEncode},
+	"number":     {arguments: 1, json: true, call: fnNumber},
+	"bool":       {arguments: 1, json: true, call: fnBool},
+	"json":       {arguments: 1, json: true, call: fnJSON},
+}
+
+func fnUUID([]string) (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "", err
+	}
+
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+func fnRandInt(args []string) (string, error) {
+	min, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("randInt: invalid minimum (%s)", args[0])
+	}
+
+	max, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("randInt: invalid maximum (%s)", args[1])
+	}
+
+	if max < min {
+		return "", fmt.Errorf("randInt: the maximum (%d) is less than the minimum (%d)", max, min)
+	}
+
+	// the span is computed in uint64, as max-min overflows int64 when the
+	// values have different signs; Int63 covers at most 2^63 values.
+	span := uint64(max) - uint64(min)
+
+	var offset int64
+
+	switch {
+	case span > math.MaxInt64:
+		return "", fmt.Errorf("randInt: the range (%d, %d) is too wide", min, max)
+	case span == math.MaxInt64:
+		offset = mathrand.Int63()
+	default:
+		offset = mathrand.Int63n(int64(span) + 1)
+	}
+
+	return strconv.FormatInt(int64(uint64(min)+uint64(offset)), 10), nil
+}
+
+func fnRandString(args []string) (string, error) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("randString: invalid length (%s)", args[0])
+	}
+
+	if n > maxRandStringLength {
+		return "", fmt.Errorf("randString: the length (%d) is greater than %d", n, maxRandStringLength)
+	}
+
+	letters := make([]byte, n)
+	for i := range letters {
+		letters[i] = randStringLetters[mathrand.Intn(len(randStringLetters))]
+	}
+
+	return string(letters), nil
+}
+
+// fnNow formats the current time with a Go layout (RFC 3339 by default), or
+// as a Unix timestamp with "unix" and "unix_ms".
+func fnNow(args []string) (string, error) {
+	now := time.Now()
+
+	if len(args) == 0 {
+		return now.Format(time.RFC3339), nil
+	}
+
+	switch args[0] {
+	case "unix":
+		return strconv.FormatInt(now.Unix(), 10), nil
+	case "unix_ms":
+		return strconv.FormatInt(now.UnixMilli(), 10), nil
+	default:
+		return now.Format(args[0]), nil
+	}
+}
+
+func fnBase64(args []string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(args[0])), nil
+}
+
+func fnSHA256(args []string) (string, error) {
+	sum := sha256.Sum256([]byte(args[0]))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func fnURLEncode(args []string) (string, error) {
+	return url.QueryEscape(args[0]), nil
+}
+
+func fnNumber(args []string) (string, error) {
+	value := strings.TrimSpace(args[0])
+	if _, err := strconv.ParseFloat(value, 64); err != nil || !json.Valid([]byte(value)) {
+		return "", fmt.Errorf("number: invalid number (%s)", args[0])
+	}
+
+	return value, nil
+}
+
+func fnBool(args []string) (string, error) {
+	value, err := strconv.ParseBool(strings.TrimSpace(args[0]))
+	if err != nil {
+		return "", fmt.Errorf("bool: invalid boolean (%s)", args[0])
+	}
+
+	return strconv.FormatBool(value), nil
+}
+
+func fnJSON(args []string) (string, error) {
+	value := strings.TrimSpace(args[0])
+	if !json.Valid([]byte(value)) {
+		return "", fmt.Errorf("json: invalid JSON (%s)", args[0])
+	}
+
+	return value, nil
+}
+
+// unquote removes the double quotes of an argument, if any.
+func unquote(arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if !strings.HasPrefix(arg, `"`) {
+		return arg, nil
+	}
+
+	value, err := strconv.Unquote(arg)
+	if err != nil {
+		return "", fmt.Errorf("invalid string argument: %s", arg)
+	}
+
+	return value, nil
+}
+
+// splitArguments splits the arguments on the commas outside double quotes.
+func splitArguments(arguments string) []string {
+	var args []string
+
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(arguments); i++ {
+		switch arguments[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				args = append(args, arguments[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(args, arguments[start:])
+}
+
+// functionName returns the name of the function of name(arguments).
+func functionName(call string) string {
+	name, _, _ := strings.Cut(call, "(")
+
+	return strings.TrimSpace(name)
+}
+
+// functionCall is a call of name(arguments) or name, with the arguments
+// split and unquoted.
+type functionCall struct {
+	function templateFunction
+	args     []string
+}
+
+// parseFunctionCall checks the name and the number of arguments of the call.
+func parseFunctionCall(call string) (*functionCall, error) {
+	name := functionName(call)
+	_, arguments, hasArguments := strings.Cut(call, "(")
+
+	f, found := templateFunctions[name]
+	if !found {
+		return nil, fmt.Errorf("the function (%s) does not exist", name)
+	}
+
+	var args []string
+
+	if hasArguments {
+		if !strings.HasSuffix(arguments, ")") {
+			return nil, fmt.Errorf("the function (%s) is missing the closing parenthesis", call)
+		}
+
+		arguments = strings.TrimSuffix(arguments, ")")
+
+		if f.arguments == 1 {
+			args = []string{arguments}
+		} else if strings.TrimSpace(arguments) != "" {
+			args = splitArguments(arguments)
+		}
+
+		for i := range args {
+			arg, err := unquote(args[i])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err.Error())
+			}
+
+			args[i] = arg
+		}
+	}
+
+	if len(args) != f.arguments && !(f.optional && len(args) == 0) {
+		return nil, fmt.Errorf("the function (%s) expects %d argument(s)", name, f.arguments)
+	}
+
+	return &functionCall{function: f, args: args}, nil
+}
+
+func (c *functionCall) run() (string, error) {
+	return c.function.call(c.args)
+}
+
+// callFunction parses and runs name(arguments) or name.
+func callFunction(call string) (string, error) {
+	c, err := parseFunctionCall(call)
+	if err != nil {
+		return "", err
+	}
+
+	return c.run()
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRandInt(t *testing.T) {
+	tests := []struct {
+		min int64
+		max int64
+	}{
+		{1, 1},
+		{-5, 5},
+		{0, 9},
+		{0, math.MaxInt64},
+		{math.MinInt64, -1},
+		{-1, math.MaxInt64 - 1},
+		{math.MaxInt64 - 1, math.MaxInt64},
+		{math.MinInt64, math.MinInt64 + 1},
+	}
+
+	for _, tt := range tests {
+		args := []string{strconv.FormatInt(tt.min, 10), strconv.FormatInt(tt.max, 10)}
+
+		for i := 0; i < 100; i++ {
+			value, err := fnRandInt(args)
+			if err != nil {
+				t.Fatalf("%v: %s", args, err.Error())
+			}
+
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < tt.min || n > tt.max {
+				t.Fatalf("%v: got %s, expected a value in the range", args, value)
+			}
+		}
+	}
+}
+
+func TestRandIntErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"a", "1"}, "invalid minimum"},
+		{[]string{"1", "9223372036854775808"}, "invalid maximum"},
+		{[]string{"2", "1"}, "is less than the minimum"},
+		{[]string{"-1", "9223372036854775807"}, "is too wide"},
+		{[]string{"-9223372036854775808", "9223372036854775807"}, "is too wide"},
+	}
+
+	for _, tt := range tests {
+		if _, err := fnRandInt(tt.args); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got %v, expected an error with %q", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestRandString(t *testing.T) {
+	for _, n := range []int{0, 1, 64, maxRandStringLength} {
+		value, err := fnRandString([]string{strconv.Itoa(n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(value) != n || strings.Trim(value, randStringLetters) != "" {
+			t.Errorf("%d: got %d characters, expected letters and numbers", n, len(value))
+		}
+	}
+
+	for _, arg := range []string{"-1", "x", strconv.Itoa(maxRandStringLength + 1), "100000000000"} {
+		if _, err := fnRandString([]string{arg}); err == nil {
+			t.Errorf("%s: expected an error", arg)
+		}
+	}
+}
+
+func TestParseFunctionCall(t *testing.T) {
+	tests := []struct {
+		call string
+		args []string
+		err  string
+	}{
+		{"uuid", nil, ""},
+		{"uuid()", nil, ""},
+		{"now", nil, ""},
+		{`now("2006-01-02")`, []string{"2006-01-02"}, ""},
+		{"randInt(1, 10)", []string{"1", "10"}, ""},
+		{`randInt("1", 10)`, []string{"1", "10"}, ""},
+		{"base64(a, b)", []string{"a, b"}, ""},
+		{`base64("a\"b")`, []string{`a"b`}, ""},
+		{"missing(1)", nil, "the function (missing) does not exist"},
+		{"randInt(1)", nil, "expects 2 argument(s)"},
+		{"randInt(1, 2, 3)", nil, "expects 2 argument(s)"},
+		{"uuid(1)", nil, "expects 0 argument(s)"},
+		{"base64", nil, "expects 1 argument(s)"},
+		{"base64(a", nil, "missing the closing parenthesis"},
+		{`base64("a)`, nil, "invalid string argument"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.call, func(t *testing.T) {
+			c, err := parseFunctionCall(tt.call)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got %v, expected an error with %q", err, tt.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(c.args, "|") != strings.Join(tt.args, "|") || len(c.args) != len(tt.args) {
+				t.Errorf("got the arguments %q, expected %q", c.args, tt.args)
+			}
+		})
+	}
+}
+
+func TestFunctionsAtLoad(t *testing.T) {
+	s := &Step{index: 1}
+
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"{%FN:randInt(1, 10)%}", ""},
+		{"{%FN:randInt(1, {%VAR:max:ENDVAR%})%}", ""},
+		{"{%FN:randInt(10, 1)%}", "cycle[1].body: column 1: randInt: the maximum (1) is less than the minimum (10)"},
+		{"a {%FN:randString(100000000000)%}", "cycle[1].body: column 3: randString: the length (100000000000) is greater than 1048576"},
+		{"{%FN:randString(2000000)%}", "cycle[1].body: column 1: randString: the length (2000000) is greater than 1048576"},
+		{"{%FN:number(abc)%}", "cycle[1].body: column 1: number: invalid number (abc)"},
+		{"{%FN:uuid(1)%}", "cycle[1].body: column 1: the function (uuid) expects 0 argument(s)"},
+		{"{%FN:nope%}", "cycle[1].body: column 1: the function (nope) does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tmpl, err := s.parseTemplate("body", tt.source)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				tag, _ := tmpl.Single()
+				if _, literal := tag.Fields[1].Literal(); literal != (tag.Compiled != nil) {
+					t.Errorf("got the compiled call %v, expected it only without tags", tag.Compiled)
+				}
+
+				return
+			}
+
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("got %v, expected %q", err, tt.err)
+			}
+		})
+	}
+}
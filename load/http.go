@@ -0,0 +1,201 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gabriellasaro/load-test/types"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	transportPerTest   = "PER_TEST"
+	transportPerWorker = "PER_WORKER"
+)
+
+const defaultMaxIdleConnsPerHost = 100
+
+// HTTPConfig tunes the transport used by the requests of the test.
+type HTTPConfig struct {
+	Transport           types.Str      `json:"transport,omitempty"`
+	KeepAlive           *bool          `json:"keep_alive,omitempty"`
+	MaxConnsPerHost     int            `json:"max_conns_per_host,omitempty"`
+	MaxIdleConnsPerHost int            `json:"max_idle_conns_per_host,omitempty"`
+	IdleTimeout         types.Duration `json:"idle_timeout,omitempty"`
+	DisableCompression  bool           `json:"disable_compression,omitempty"`
+	connections         int64
+	shared              *http.Transport
+}
+
+func (hc *HTTPConfig) transport() string {
+	transport := hc.Transport.TrimSpace().ToUpper()
+	if transport.IsEmpty() {
+		return transportPerTest
+	}
+
+	return transport.String()
+}
+
+func (hc *HTTPConfig) keepAlive() bool {
+	return hc.KeepAlive == nil || *hc.KeepAlive
+}
+
+func (hc *HTTPConfig) maxIdleConnsPerHost() int {
+	if hc.MaxIdleConnsPerHost > 0 {
+		return hc.MaxIdleConnsPerHost
+	}
+
+	if hc.MaxConnsPerHost > 0 {
+		return hc.MaxConnsPerHost
+	}
+
+	return defaultMaxIdleConnsPerHost
+}
+
+func (hc *HTTPConfig) preload() error {
+	switch hc.transport() {
+	case transportPerTest, transportPerWorker:
+	default:
+		return fmt.Errorf("http.transport (%s) is not valid", hc.Transport)
+	}
+
+	if hc.MaxConnsPerHost < 0 || hc.MaxIdleConnsPerHost < 0 {
+		return errors.New("http connection limits cannot be negative")
+	}
+
+	if hc.IdleTimeout < 0 {
+		return errors.New("http.idle_timeout cannot be negative")
+	}
+
+	if hc.transport() == transportPerTest {
+		hc.shared = hc.newTransport()
+	}
+
+	return nil
+}
+
+// dial opens the connections of the transports, counting them.
+func (hc *HTTPConfig) dial(dialer *net.Dialer) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err == nil {
+			atomic.AddInt64(&hc.connections, 1)
+		}
+
+		return conn, err
+	}
+}
+
+func (hc *HTTPConfig) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = hc.dial(&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	})
+	transport.DisableKeepAlives = !hc.keepAlive()
+	transport.MaxConnsPerHost = hc.MaxConnsPerHost
+	transport.MaxIdleConnsPerHost = hc.maxIdleConnsPerHost()
+	// No limit for all hosts (DefaultTransport has 100), so only the limit
+	// per host applies.
+	transport.MaxIdleConns = 0
+	transport.DisableCompression = hc.DisableCompression
+
+	if hc.IdleTimeout > 0 {
+		transport.IdleConnTimeout = hc.IdleTimeout.Duration()
+	}
+
+	return transport
+}
+
+// connectionsOpened returns how many connections were opened by the test.
+func (hc *HTTPConfig) connectionsOpened() int64 {
+	return atomic.LoadInt64(&hc.connections)
+}
+
+// session is the state of a worker kept between its cycles.
+type session struct {
+	worker    int
+	client    *http.Client
+	transport *http.Transport
+	visited   []*url.URL
+	variables map[string]string
+	data      map[string]string
+	iteration int
+}
+
+func (hc *HTTPConfig) newSession(worker int) *session {
+	transport := hc.shared
+	if transport == nil {
+		transport = hc.newTransport()
+	}
+
+	ss := &session{
+		worker:    worker,
+		client:    &http.Client{Transport: transport},
+		transport: transport,
+	}
+	ss.resetCookies()
+
+	return ss
+}
+
+func (ss *session) close() {
+	ss.transport.CloseIdleConnections()
+}
+
+// sessions keeps the session of each worker of a scenario.
+type sessions struct {
+	mutex    sync.Mutex
+	config   *HTTPConfig
+	sessions map[int]*session
+}
+
+func newSessions(config *HTTPConfig) *sessions {
+	return &sessions{
+		config:   config,
+		sessions: make(map[int]*session),
+	}
+}
+
+func (ss *sessions) get(worker int) *session {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	s, found := ss.sessions[worker]
+	if !found {
+		s = ss.config.newSession(worker)
+		ss.sessions[worker] = s
+	}
+
+	return s
+}
+
+func (ss *sessions) close() {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	for _, s := range ss.sessions {
+		s.close()
+	}
+}
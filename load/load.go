@@ -17,40 +17,35 @@ limitations under the License.
 package load
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/gabriellasaro/load-test/logwriter"
 	"github.com/gabriellasaro/load-test/metrics"
 	"github.com/gabriellasaro/load-test/types"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 type DataTest struct {
-	Loops     int       `json:"loops"`
-	Parallel  int       `json:"parallel"`
-	LogFolder types.Str `json:"log"`
-	history   *logwriter.LogWriter
-	Variables []Variable `json:"variables"`
-}
-
-func (lt *DataTest) totalLoops() int {
-	if lt.Loops <= 0 {
-		return 1
-	}
-
-	return lt.Loops
-}
-
-func (lt *DataTest) workersPerLoop() int {
-	if lt.Parallel <= 0 {
-		return 1
-	}
-
-	return lt.Parallel
+	Scenario
+	Scenarios   map[string]*Scenario `json:"scenarios,omitempty"`
+	Thresholds  []Threshold          `json:"thresholds,omitempty"`
+	HTTP        HTTPConfig           `json:"http"`
+	LogFolder   types.Str            `json:"log"`
+	history     *logwriter.LogWriter
+	Variables   []Variable `json:"variables"`
+	Data        []*Data    `json:"data,omitempty"`
+	variables   map[string]string
+	runID       string
+	started     time.Time
+	dataStopped int32
+	scenarios   []*Scenario
+	abortedBy   *Threshold
 }
 
 func (lt *DataTest) logFolder() string {
@@ -58,15 +53,23 @@ func (lt *DataTest) logFolder() string {
 }
 
 func (lt *DataTest) preload() error {
-	if lt.Loops < 0 {
-		return errors.New("\"loops\" must be greater than zero")
+	if err := lt.validateVariables(); err != nil {
+		return err
 	}
 
-	if lt.Parallel < 0 {
-		return errors.New("\"parallel\" must be greater than zero")
+	if err := lt.preloadData(); err != nil {
+		return err
 	}
 
-	if err := lt.validateVariables(); err != nil {
+	if err := lt.HTTP.preload(); err != nil {
+		return err
+	}
+
+	if err := lt.preloadScenarios(); err != nil {
+		return err
+	}
+
+	if err := lt.preloadThresholds(); err != nil {
 		return err
 	}
 
@@ -109,27 +112,27 @@ func (lt *DataTest) startLog() error {
 		return err
 	}
 
+	for _, sc := range lt.scenarios {
+		if err := sc.startLog(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (lt *DataTest) startLogForLoop(loop int) (*logByLoop, error) {
-	if lt.LogFolder.TrimSpace().IsEmpty() {
-		return nil, nil
+func (lt *DataTest) showTitle(title string, sc *Scenario) {
+	if lt.hasScenarios() {
+		title += fmt.Sprintf(" | SCENARIO [%s]", sc.name)
 	}
 
-	logLoop := newLogByLoop(lt.logFolder(), loop)
-	logLoop.newLogHistory()
-
-	return logLoop, nil
+	lt.sendDataToHistory("\n"+title, true)
 }
 
-func (lt *DataTest) showAveragesOfSteps() {
-	lt.sendDataToHistory(
-		"\nAVERAGES OF STEPS",
-		true,
-	)
+func (lt *DataTest) showAveragesOfSteps(sc *Scenario) {
+	lt.showTitle("AVERAGES OF STEPS", sc)
 
-	for _, at := range durationMetrics.AveragesOfSteps() {
+	for _, at := range sc.metrics.AveragesOfSteps() {
 		lt.sendDataToHistory(
 			fmt.Sprintf("\tSTEP [%s]: %s", at.Index(), at.Average()),
 			true,
@@ -137,13 +140,10 @@ func (lt *DataTest) showAveragesOfSteps() {
 	}
 }
 
-func (lt *DataTest) showAveragesOfLoopSteps() {
-	lt.sendDataToHistory(
-		"\nAVERAGES OF LOOP STEPS",
-		true,
-	)
+func (lt *DataTest) showAveragesOfLoopSteps(sc *Scenario) {
+	lt.showTitle("AVERAGES OF LOOP STEPS", sc)
 
-	for _, at := range durationMetrics.AveragesOfLoopSteps() {
+	for _, at := range sc.metrics.AveragesOfLoopSteps() {
 		lt.sendDataToHistory(
 			fmt.Sprintf("\tLOOP [%s] | STEP [%s]: %s", at.Loop(), at.Index(), at.Average()),
 			true,
@@ -151,9 +151,217 @@ func (lt *DataTest) showAveragesOfLoopSteps() {
 	}
 }
 
-var durationMetrics = metrics.NewMetrics()
+func (lt *DataTest) showStatisticsOfLoopSteps(sc *Scenario) {
+	lt.showTitle("STATISTICS OF LOOP STEPS", sc)
+
+	for _, st := range sc.metrics.StatisticsOfLoopSteps() {
+		lt.sendDataToHistory(
+			fmt.Sprintf(
+				"\tLOOP [%s] | STEP [%s]: COUNT: %d | MIN: %s | MAX: %s | MEAN: %s | STDDEV: %s | P50: %s | P90: %s | P95: %s | P99: %s | P99.9: %s",
+				st.Loop(),
+				st.Index(),
+				st.Count(),
+				st.Min(),
+				st.Max(),
+				st.Mean(),
+				st.StdDev(),
+				st.P50(),
+				st.P90(),
+				st.P95(),
+				st.P99(),
+				st.P999(),
+			),
+			true,
+		)
+	}
+
+	if truncated := sc.metrics.TruncatedLoopSteps(); truncated > 0 {
+		lt.sendDataToHistory(
+			fmt.Sprintf("\tTRUNCATED: %d durations of the loops after %d are only in the statistics of steps", truncated, metrics.MaxLoopsOfLoopSteps),
+			true,
+		)
+	}
+}
+
+func (lt *DataTest) showStatisticsOfSteps(sc *Scenario) {
+	lt.showTitle("STATISTICS OF STEPS", sc)
+
+	for _, st := range sc.metrics.StatisticsOfSteps() {
+		lt.sendDataToHistory(
+			fmt.Sprintf(
+				"\tSTEP [%s]: COUNT: %d | MIN: %s | MAX: %s | MEAN: %s | STDDEV: %s | P50: %s | P90: %s | P95: %s | P99: %s | P99.9: %s",
+				st.Index(),
+				st.Count(),
+				st.Min(),
+				st.Max(),
+				st.Mean(),
+				st.StdDev(),
+				st.P50(),
+				st.P90(),
+				st.P95(),
+				st.P99(),
+				st.P999(),
+			),
+			true,
+		)
+	}
+}
+
+func (lt *DataTest) showPhasesOfSteps(sc *Scenario) {
+	lt.showTitle("PHASES OF STEPS (MEAN)", sc)
+
+	for _, st := range sc.metrics.StatisticsOfSteps() {
+		index, _ := strconv.Atoi(st.Index())
+
+		means := make([]string, len(phases))
+		for i, phase := range phases {
+			means[i] = fmt.Sprintf("%s: %s", strings.ToUpper(phase), sc.metrics.HistogramOfPhase(index, phase).Mean())
+		}
+
+		lt.sendDataToHistory(
+			fmt.Sprintf("\tSTEP [%s]: %s", st.Index(), strings.Join(means, " | ")),
+			true,
+		)
+	}
+}
+
+func (lt *DataTest) showRequests(title string, r *metrics.Requests) {
+	lt.sendDataToHistory(
+		fmt.Sprintf(
+			"\t%s: REQUESTS: %d | RATE: %.2f/s | BYTES: %d | ERRORS: %d (%.2f%%)",
+			title,
+			r.Requests(),
+			r.Rate(),
+			r.Bytes(),
+			r.ErrorCount(),
+			r.ErrorRate()*100,
+		),
+		true,
+	)
+
+	if codes := r.Codes(); len(codes) > 0 {
+		statusCodes := make([]string, len(codes))
+		for i, code := range codes {
+			statusCodes[i] = fmt.Sprintf("%d: %d", code, r.StatusCodes()[code])
+		}
+
+		lt.sendDataToHistory("\t\tSTATUS CODES: "+strings.Join(statusCodes, " | "), true)
+	}
+
+	if kinds := r.ErrorKinds(); len(kinds) > 0 {
+		errorKinds := make([]string, len(kinds))
+		for i, kind := range kinds {
+			errorKinds[i] = fmt.Sprintf("%s: %d", kind, r.Errors()[kind])
+		}
+
+		lt.sendDataToHistory("\t\tERRORS: "+strings.Join(errorKinds, " | "), true)
+	}
+}
+
+func (lt *DataTest) showRequestsOfSteps(sc *Scenario) {
+	lt.showTitle("REQUESTS OF STEPS", sc)
+
+	for _, r := range sc.metrics.RequestsOfSteps() {
+		lt.showRequests(fmt.Sprintf("STEP [%s]", r.Index()), r)
+	}
+
+	lt.showRequests("TOTAL", sc.metrics.TotalRequests())
+
+	if dataErrors := sc.metrics.DataErrors(); dataErrors > 0 {
+		lt.sendDataToHistory(fmt.Sprintf("\tDATA ERRORS: %d (iterations without a row, no request sent)", dataErrors), true)
+	}
+
+	if interrupted := sc.metrics.Interrupted(); interrupted > 0 {
+		lt.sendDataToHistory(fmt.Sprintf("\tINTERRUPTED: %d (cycles cut short by the end of the test, not counted as errors)", interrupted), true)
+	}
+}
+
+func (lt *DataTest) showConnections() {
+	lt.sendDataToHistory(
+		fmt.Sprintf("\nCONNECTIONS\n\tOPENED: %d", lt.HTTP.connectionsOpened()),
+		true,
+	)
+}
+
+func (lt *DataTest) showArrivals(sc *Scenario) {
+	if sc.executor() != executorArrivalRate {
+		return
+	}
+
+	lt.showTitle("ARRIVALS", sc)
+
+	lt.sendDataToHistory(
+		fmt.Sprintf(
+			"\tSTARTED: %d | DROPPED: %d",
+			sc.arrivals.started,
+			sc.arrivals.dropped,
+		),
+		true,
+	)
+}
+
+// runScenarios runs all scenarios at the same time and returns the first
+// error. The scenarios are stopped early when a threshold with abort_on_fail
+// fails.
+func (lt *DataTest) runScenarios() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	watching := make(chan struct{})
+
+	// The watcher is stopped and joined before returning, so abortedBy is
+	// not written after the scenarios end.
+	defer func() {
+		close(done)
+		<-watching
+	}()
+
+	go func() {
+		defer close(watching)
+
+		lt.watchThresholds(done, func(t *Threshold) {
+			lt.abortedBy = t
+			lt.sendDataToHistory(fmt.Sprintf("\nTEST ABORTED: THRESHOLD (%s) FAILED", t.Expression), true)
+			cancel()
+		})
+	}()
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(lt.scenarios))
+
+	wg.Add(len(lt.scenarios))
+
+	for i, sc := range lt.scenarios {
+		go func(i int, sc *Scenario) {
+			defer wg.Done()
+
+			errs[i] = sc.run(ctx)
+		}(i, sc)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Run(filename string, options *Options) error {
+	if options == nil {
+		options = new(Options)
+	}
+
+	outputs, err := parseOutputs(options.Outputs)
+	if err != nil {
+		return err
+	}
 
-func Run(filename string) error {
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		return err
@@ -168,67 +376,54 @@ func Run(filename string) error {
 		return err
 	}
 
-	variables := load.getVariablesForReplace()
+	load.variables = load.variablesByKey()
 
 	if err := load.startLog(); err != nil {
 		return err
 	}
 
+	load.runID, err = fnUUID(nil)
+	if err != nil {
+		return err
+	}
+
 	load.newLogHistory()
 	load.sendDataToHistory("HISTORY\n", false)
+	load.sendDataToHistory(fmt.Sprintf("RUN ID: %s\n", load.runID), true)
 
-	loop := 1
-	for {
-		var wgLoop sync.WaitGroup
+	start := time.Now()
+	load.started = start
 
-		wgLoop.Add(load.workersPerLoop())
+	if err := load.runScenarios(); err != nil {
+		return err
+	}
 
-		load.sendDataToHistory(fmt.Sprintf("\nLOOP %d\n", loop), true)
+	end := time.Now()
 
-		logLoop, err := load.startLogForLoop(loop)
-		if err != nil {
-			return err
-		}
+	for _, sc := range load.scenarios {
+		load.showAveragesOfLoopSteps(sc)
+		load.showAveragesOfSteps(sc)
+		load.showStatisticsOfLoopSteps(sc)
+		load.showStatisticsOfSteps(sc)
+		load.showPhasesOfSteps(sc)
+		load.showRequestsOfSteps(sc)
+		load.showArrivals(sc)
+	}
 
-		for w := 1; w <= load.workersPerLoop(); w++ {
-			var cycle Cycle
-			if err := json.Unmarshal(content, &cycle); err != nil {
-				return err
-			}
-
-			go func(worker int) {
-				defer wgLoop.Done()
-
-				err := cycle.execute(variables, loop, worker, logLoop)
-				logTime := time.Now().Format("01-02-2006 15:04:05")
-
-				if err != nil {
-					load.sendDataToHistory(
-						fmt.Sprintf("%s: LOOP: %d | WORKER: %d | ERROR: %q", logTime, loop, worker, err),
-						true,
-					)
-				} else {
-					load.sendDataToHistory(
-						fmt.Sprintf("%s: LOOP: %d | WORKER: %d | SUCCESS", logTime, loop, worker),
-						true,
-					)
-				}
-			}(w)
-		}
+	load.showConnections()
 
-		wgLoop.Wait()
-		logLoop.waitHistory()
+	results := load.evaluateThresholds(false)
+	load.showThresholds(results)
 
-		if loop == load.totalLoops() {
-			break
-		}
+	load.waitHistory()
 
-		loop += 1
+	if err := load.writeOutputs(outputs, load.summary(filename, content, start, end, results)); err != nil {
+		return err
 	}
 
-	load.showAveragesOfLoopSteps()
-	load.showAveragesOfSteps()
-	load.waitHistory()
+	if thresholdsFailed(results) {
+		return ErrThresholdsFailed
+	}
 
 	return nil
 }
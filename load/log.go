@@ -23,15 +23,23 @@ import (
 )
 
 type logByLoop struct {
-	loop    int
-	folder  string
-	history *logwriter.LogWriter
+	filename string
+	title    string
+	history  *logwriter.LogWriter
 }
 
 func newLogByLoop(logFolder string, loop int) *logByLoop {
 	return &logByLoop{
-		loop:   loop,
-		folder: logFolder,
+		filename: logFolder + fmt.Sprintf("/%d.loop.txt", loop),
+		title:    fmt.Sprintf("LOOP [%d]\n", loop),
+	}
+}
+
+// newLogByWorker is used by executors where each worker runs its own loops.
+func newLogByWorker(logFolder string, worker int) *logByLoop {
+	return &logByLoop{
+		filename: logFolder + fmt.Sprintf("/%d.worker.txt", worker),
+		title:    fmt.Sprintf("WORKER [%d]\n", worker),
 	}
 }
 
@@ -41,9 +49,9 @@ func (lw *logByLoop) logDisabled() bool {
 
 func (lw *logByLoop) newLogHistory() {
 	if !lw.logDisabled() {
-		lw.history = logwriter.NewLogWriter(lw.folder + fmt.Sprintf("/%d.loop.txt", lw.loop))
+		lw.history = logwriter.NewLogWriter(lw.filename)
 		lw.history.Writer()
-		lw.history.Send(fmt.Sprintf("LOOP [%d]\n", lw.loop))
+		lw.history.Send(lw.title)
 	}
 }
 
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// regexGroupMatch is a compiled pattern:group.
+type regexGroupMatch struct {
+	re    *regexp.Regexp
+	group int
+}
+
+// compileRegex compiles the variable written as pattern:group.
+func compileRegex(variable string) (*regexGroupMatch, error) {
+	i := strings.LastIndexByte(variable, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid regex group: %q", variable)
+	}
+
+	pattern := variable[:i]
+
+	group, err := strconv.Atoi(variable[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex group: %q", variable)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex (%s): %s", pattern, err.Error())
+	}
+
+	if group > re.NumSubexp() {
+		return nil, fmt.Errorf("the regex (%s) has no group %d", pattern, group)
+	}
+
+	return &regexGroupMatch{re: re, group: group}, nil
+}
+
+// matchRegex returns the group of the first match of the regex in the body.
+func (r *ResponseCycle) matchRegex(m *regexGroupMatch) (string, error) {
+	match := m.re.FindSubmatch(r.Body)
+	if match == nil {
+		return "", fmt.Errorf("the regex (%s) did not match the response", m.re.String())
+	}
+
+	return string(match[m.group]), nil
+}
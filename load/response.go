@@ -17,124 +17,109 @@ limitations under the License.
 package load
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/gabriellasaro/load-test/types"
-	"regexp"
+	"github.com/gabriellasaro/load-test/jsonquery"
+	"github.com/gabriellasaro/load-test/xpath"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
-var regexVarResp = regexp.MustCompile(`{%RESP\[([0-9]+)\]:([A-Z_]+):ENDRESP%}`)
-
+// ResponseCycle keeps the response of a step. Duration is the time until the
+// headers are received and TotalDuration includes the reading of the body.
 type ResponseCycle struct {
-	URL        string
-	StatusCode int
-	Body       []byte
-	Duration   time.Duration
+	URL           string
+	FinalURL      string
+	StatusCode    int
+	Header        http.Header
+	ContentLength int64
+	Body          []byte
+	Size          int64
+	Duration      time.Duration
+	TotalDuration time.Duration
+	Timings       Timings
+
+	parsedDocument *xpath.Node
 }
 
-func (r *ResponseCycle) bodyToInterface() interface{} {
+func (r *ResponseCycle) bodyToInterface() (interface{}, error) {
 	if r.Body == nil {
-		return nil
+		return nil, errors.New("the response has no body")
 	}
 
-	var body map[string]interface{}
-
-	if err := json.Unmarshal(r.Body, &body); err != nil {
-		var body []map[string]interface{}
+	var body interface{}
 
-		if err := json.Unmarshal(r.Body, &body); err != nil {
-			return err
-		}
+	decoder := json.NewDecoder(bytes.NewReader(r.Body))
+	decoder.UseNumber()
 
-		return body
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("the response body is not valid JSON: %s", err.Error())
 	}
 
-	return body
+	return body, nil
 }
 
-func (r *ResponseCycle) getValueInResponseByPath(path []string) (string, error) {
-	value, err := getValueInResponse(r.bodyToInterface(), path)
+// searchJSON returns the value of the query with its JSON type.
+func (r *ResponseCycle) searchJSON(query *jsonquery.Query) (interface{}, error) {
+	body, err := r.bodyToInterface()
 	if err != nil {
-		return "", fmt.Errorf("%s: %q", err.Error(), path)
+		return nil, fmt.Errorf("%s: %q", err.Error(), query.String())
+	}
+
+	value := query.Search(body)
+	if value == nil {
+		return nil, fmt.Errorf("the path was not found: %q", query.String())
 	}
 
 	return value, nil
 }
 
-func getValueInResponse(body interface{}, path []string) (string, error) {
-	if body, ok := body.(map[string]interface{}); ok {
-		if value, found := body[path[0]]; found {
-			if len(path) == 1 {
-				return fmt.Sprintf("%v", value), nil
-			}
-
-			return getValueInResponse(body[path[0]], path[1:])
-		}
+// getValueInResponseByQuery returns the value of the query as text.
+func (r *ResponseCycle) getValueInResponseByQuery(query *jsonquery.Query) (string, error) {
+	value, err := r.searchJSON(query)
+	if err != nil {
+		return "", err
 	}
 
-	if len(path) >= 2 {
-		if body, ok := body.([]interface{}); ok {
-			key, err := strconv.Atoi(path[0])
-			if err != nil {
-				return "", err
-			}
-
-			if key > (len(body) - 1) {
-				return "", fmt.Errorf("the index [%d] provided does not exist", key)
-			}
-
-			if body, ok := body[key].(map[string]interface{}); ok {
-				if value, found := body[path[1]]; found {
-					if len(path) == 2 {
-						return fmt.Sprintf("%v", value), nil
-					}
-
-					if body, ok := value.(map[string]interface{}); ok {
-						return getValueInResponse(body, path[2:])
-					}
-				}
-			}
-		}
-	}
+	return jsonquery.Format(value)
+}
 
-	return "", errors.New("the path was not found")
+// numericResponseVariables are the {%RESP...%} variables that are numbers.
+var numericResponseVariables = map[string]bool{
+	"STATUS_CODE":    true,
+	"CONTENT_LENGTH": true,
+	"SIZE":           true,
+	"DURATION":       true,
+	"TOTAL_DURATION": true,
 }
 
 func (r *ResponseCycle) getValueInResponseVariable(key string) (string, error) {
-	switch key {
+	name, argument, _ := strings.Cut(key, ":")
+
+	switch name {
 	case "STATUS_CODE":
 		return fmt.Sprintf("%d", r.StatusCode), nil
-	default:
-		return "", fmt.Errorf("the variable is not valid: %s", key)
-	}
-}
-
-func getResponseVariables(currentCycle int, cycle *[]*Step, data string) ([]*types.IndexVariable, error) {
-	indexVars, err := types.GetMatchesForIndexVariables(regexVarResp, data)
-	if err != nil {
-		return nil, err
-	}
-
-	for i := 0; i < len(indexVars); i++ {
-		if indexVars[i].Index() >= currentCycle {
-			return nil, fmt.Errorf("cannot use a variable that does not yet exist: %s", indexVars[i].Key())
+	case "HEADER":
+		if _, found := r.Header[http.CanonicalHeaderKey(argument)]; !found {
+			return "", fmt.Errorf("the header (%s) was not found in the response", argument)
 		}
 
-		step, err := getStepByIndex(cycle, indexVars[i].Index())
-		if err != nil {
-			return nil, err
-		}
-
-		value, err := step.response.getValueInResponseVariable(indexVars[i].Path())
-		if err != nil {
-			return nil, err
-		}
-
-		indexVars[i].SetValue(value)
+		return r.Header.Get(argument), nil
+	case "URL":
+		return r.FinalURL, nil
+	case "CONTENT_LENGTH":
+		return strconv.FormatInt(r.ContentLength, 10), nil
+	case "SIZE":
+		return strconv.FormatInt(r.Size, 10), nil
+	case "DURATION":
+		return strconv.FormatInt(r.Duration.Milliseconds(), 10), nil
+	case "TOTAL_DURATION":
+		return strconv.FormatInt(r.TotalDuration.Milliseconds(), 10), nil
+	default:
+		return "", fmt.Errorf("the variable is not valid: %s", key)
 	}
-
-	return indexVars, nil
 }
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"github.com/gabriellasaro/load-test/jsonquery"
+	"strings"
+	"testing"
+)
+
+func getValueInResponseByPath(r *ResponseCycle, path string) (string, error) {
+	query, err := jsonquery.Compile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return r.getValueInResponseByQuery(query)
+}
+
+func TestGetValueInResponseByQuery(t *testing.T) {
+	response := &ResponseCycle{Body: []byte(`{"data": {"token": "t\"q", "id": 10, "user": {"a": 1}, "empty": null}, "list": [1, 2]}`)}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"data.token", `t"q`},
+		{"data.id", `10`},
+		{"data.user", `{"a":1}`},
+		{"list", `[1,2]`},
+		{"list[-1]", `2`},
+	}
+
+	for _, tt := range tests {
+		value, err := getValueInResponseByPath(response, tt.path)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.path, err.Error())
+		}
+
+		if value != tt.expected {
+			t.Errorf("%s: got %s, expected %s", tt.path, value, tt.expected)
+		}
+	}
+}
+
+func TestGetValueInResponseByQueryErrors(t *testing.T) {
+	tests := []struct {
+		body    string
+		path    string
+		message string
+	}{
+		{`{"data": {}}`, "data.missing", `the path was not found: "data.missing"`},
+		{`{"data": {"empty": null}}`, "data.empty", `the path was not found: "data.empty"`},
+		{`{"data": [1]}`, "data[1]", `the path was not found: "data[1]"`},
+		{`<html></html>`, "data", "the response body is not valid JSON"},
+		{`{}`, "data[", "invalid query (data[) at column 6"},
+	}
+
+	for _, tt := range tests {
+		response := &ResponseCycle{Body: []byte(tt.body)}
+
+		_, err := getValueInResponseByPath(response, tt.path)
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.path)
+		}
+
+		if !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: got %q, expected %q", tt.path, err.Error(), tt.message)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabriellasaro/load-test/metrics"
+	"github.com/gabriellasaro/load-test/types"
+	"path"
+	"sort"
+	"time"
+)
+
+const defaultScenario = "default"
+
+// Scenario is a user journey (cycle) with its own executor settings. The
+// root of the test file is the default scenario.
+type Scenario struct {
+	Executor     types.Str       `json:"executor"`
+	Loops        int             `json:"loops,omitempty"`
+	Parallel     int             `json:"parallel,omitempty"`
+	Rate         int             `json:"rate,omitempty"`
+	MaxInFlight  int             `json:"max_in_flight,omitempty"`
+	Stages       []Stage         `json:"stages,omitempty"`
+	Duration     types.Duration  `json:"duration,omitempty"`
+	GracePeriod  *types.Duration `json:"grace_period,omitempty"`
+	ThinkTime    *ThinkTime      `json:"think_time,omitempty"`
+	Pacing       types.Duration  `json:"pacing,omitempty"`
+	ResetCookies bool            `json:"reset_cookies,omitempty"`
+	Weight       float64         `json:"weight,omitempty"`
+	Cycle        json.RawMessage `json:"cycle,omitempty"`
+	name         string
+	test         *DataTest
+	folder       string
+	deadline     time.Time
+	arrivals     arrivals
+	metrics      *metrics.Metrics
+	cycle        *Cycle
+	sessions     *sessions
+}
+
+func (sc *Scenario) totalLoops() int {
+	if sc.Loops <= 0 {
+		return 1
+	}
+
+	return sc.Loops
+}
+
+func (sc *Scenario) workersPerLoop() int {
+	if sc.Parallel <= 0 {
+		return 1
+	}
+
+	return sc.Parallel
+}
+
+func (sc *Scenario) hasDuration() bool {
+	return sc.Duration > 0
+}
+
+// runContext returns the context shared by all requests of the scenario. When
+// a grace period is informed, in-flight cycles are cut off once it expires
+// after the duration; otherwise they are allowed to finish.
+func (sc *Scenario) runContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if !sc.hasDuration() || sc.GracePeriod == nil {
+		return context.WithCancel(parent)
+	}
+
+	return context.WithTimeout(parent, sc.Duration.Duration()+sc.GracePeriod.Duration())
+}
+
+func (sc *Scenario) finished(loop int) bool {
+	if sc.test.dataExhausted() {
+		return true
+	}
+
+	if sc.hasDuration() {
+		return !time.Now().Before(sc.deadline)
+	}
+
+	return loop == sc.totalLoops()
+}
+
+// errorf prefixes the errors of named scenarios with the scenario name.
+func (sc *Scenario) errorf(format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	if !sc.test.hasScenarios() {
+		return err
+	}
+
+	return fmt.Errorf("scenarios[%s]: %s", sc.name, err.Error())
+}
+
+func (sc *Scenario) preload() error {
+	if sc.Loops < 0 {
+		return sc.errorf("\"loops\" must be greater than zero")
+	}
+
+	if sc.Parallel < 0 {
+		return sc.errorf("\"parallel\" must be greater than zero")
+	}
+
+	if sc.Duration < 0 {
+		return sc.errorf("\"duration\" must be greater than zero")
+	}
+
+	if sc.GracePeriod != nil && *sc.GracePeriod < 0 {
+		return sc.errorf("\"grace_period\" cannot be negative")
+	}
+
+	if sc.Pacing < 0 {
+		return sc.errorf("\"pacing\" cannot be negative")
+	}
+
+	if sc.ThinkTime != nil {
+		if err := sc.ThinkTime.validate(); err != nil {
+			return sc.errorf("%s", err.Error())
+		}
+	}
+
+	if err := sc.validateExecutor(); err != nil {
+		return sc.errorf("%s", err.Error())
+	}
+
+	sc.metrics = metrics.NewMetrics()
+	sc.sessions = newSessions(&sc.test.HTTP)
+
+	cycle, err := sc.newCycle()
+	if err != nil {
+		return sc.errorf("%s", err.Error())
+	}
+
+	if err := cycle.preload(); err != nil {
+		return sc.errorf("%s", err.Error())
+	}
+
+	sc.cycle = cycle
+
+	return nil
+}
+
+// inherit copies the settings not defined by the scenario from the root of
+// the test file. The inherited number of workers and rate are split among the
+// scenarios according to their weights; part returns the share of the
+// scenario of a value of the root.
+func (sc *Scenario) inherit(root *Scenario, part func(value int) int) error {
+	if sc.Executor.TrimSpace().IsEmpty() {
+		sc.Executor = root.Executor
+	}
+
+	if sc.Loops == 0 {
+		sc.Loops = root.Loops
+	}
+
+	if sc.Parallel == 0 {
+		sc.Parallel = part(root.Parallel)
+
+		if sc.Parallel == 0 && root.Parallel > 0 {
+			return sc.errorf("the \"parallel\" (%d) of the root is less than the number of scenarios", root.Parallel)
+		}
+	}
+
+	if sc.Rate == 0 {
+		sc.Rate = part(root.Rate)
+
+		if sc.Rate == 0 && root.Rate > 0 {
+			return sc.errorf("the \"rate\" (%d) of the root is less than the number of scenarios", root.Rate)
+		}
+	}
+
+	if sc.MaxInFlight == 0 {
+		sc.MaxInFlight = part(root.MaxInFlight)
+
+		if sc.MaxInFlight == 0 && root.MaxInFlight > 0 {
+			return sc.errorf("the \"max_in_flight\" (%d) of the root is less than the number of scenarios", root.MaxInFlight)
+		}
+	}
+
+	if len(sc.Stages) == 0 && (sc.Executor.TrimSpace().IsEmpty() || sc.executor() == executorRamping) {
+		for _, stage := range root.Stages {
+			sc.Stages = append(sc.Stages, Stage{
+				Duration: stage.Duration,
+				Target:   part(stage.Target),
+			})
+		}
+	}
+
+	if sc.Duration == 0 && len(sc.Stages) == 0 {
+		sc.Duration = root.Duration
+	}
+
+	if sc.GracePeriod == nil {
+		sc.GracePeriod = root.GracePeriod
+	}
+
+	if sc.ThinkTime == nil {
+		sc.ThinkTime = root.ThinkTime
+	}
+
+	if sc.Pacing == 0 {
+		sc.Pacing = root.Pacing
+	}
+
+	if !sc.ResetCookies {
+		sc.ResetCookies = root.ResetCookies
+	}
+
+	if len(sc.Cycle) == 0 {
+		sc.Cycle = root.Cycle
+	}
+
+	return nil
+}
+
+// splitByWeight splits the value among the weights by the largest remainder
+// method, so the parts add up to the value. When the value allows it, every
+// part is at least 1, taken from the part most above its exact share.
+func splitByWeight(value int, weights []float64) []int {
+	parts := make([]int, len(weights))
+	if value <= 0 {
+		for i := range parts {
+			parts[i] = value
+		}
+
+		return parts
+	}
+
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+
+	exact := make([]float64, len(weights))
+	order := make([]int, len(weights))
+	left := value
+
+	for i, weight := range weights {
+		exact[i] = float64(value) * weight / total
+		parts[i] = int(exact[i])
+		order[i] = i
+		left -= parts[i]
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return exact[order[a]]-float64(parts[order[a]]) > exact[order[b]]-float64(parts[order[b]])
+	})
+
+	for i := 0; left > 0; i++ {
+		parts[order[i%len(order)]]++
+		left--
+	}
+
+	if value < len(parts) {
+		return parts
+	}
+
+	for i := range parts {
+		if parts[i] > 0 {
+			continue
+		}
+
+		donor := -1
+		for j := range parts {
+			if parts[j] > 1 && (donor < 0 || float64(parts[j])-exact[j] > float64(parts[donor])-exact[donor]) {
+				donor = j
+			}
+		}
+
+		parts[donor]--
+		parts[i]++
+	}
+
+	return parts
+}
+
+func (sc *Scenario) newCycle() (*Cycle, error) {
+	cycle := &Cycle{metrics: sc.metrics}
+	if len(sc.Cycle) == 0 {
+		return cycle, nil
+	}
+
+	// the numbers of body_json are kept as json.Number, so they are sent as
+	// they were written.
+	decoder := json.NewDecoder(bytes.NewReader(sc.Cycle))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&cycle.Steps); err != nil {
+		return nil, err
+	}
+
+	return cycle, nil
+}
+
+func (sc *Scenario) logDisabled() bool {
+	return sc.test.logDisabled()
+}
+
+func (sc *Scenario) startLog() error {
+	if sc.logDisabled() {
+		return nil
+	}
+
+	sc.folder = sc.test.logFolder()
+	if sc.test.hasScenarios() {
+		sc.folder = path.Join(sc.folder, sc.name)
+
+		return startLogFolder(sc.folder)
+	}
+
+	return nil
+}
+
+func (sc *Scenario) startLogForLoop(loop int) (*logByLoop, error) {
+	if sc.logDisabled() {
+		return nil, nil
+	}
+
+	logLoop := newLogByLoop(sc.folder, loop)
+	logLoop.newLogHistory()
+
+	return logLoop, nil
+}
+
+func (sc *Scenario) startLogForWorker(worker int) *logByLoop {
+	if sc.logDisabled() {
+		return nil
+	}
+
+	logWorker := newLogByWorker(sc.folder, worker)
+	logWorker.newLogHistory()
+
+	return logWorker
+}
+
+// logPrefix identifies the scenario in the history when there is more than one.
+func (sc *Scenario) logPrefix() string {
+	if !sc.test.hasScenarios() {
+		return ""
+	}
+
+	return fmt.Sprintf("SCENARIO: %s | ", sc.name)
+}
+
+func (sc *Scenario) run(parent context.Context) error {
+	ctx, cancel := sc.runContext(parent)
+	defer cancel()
+
+	sc.deadline = time.Now().Add(sc.Duration.Duration())
+
+	sc.metrics.Start()
+	defer sc.metrics.Stop()
+	defer sc.sessions.close()
+
+	switch sc.executor() {
+	case executorIndependent:
+		return sc.runIndependent(ctx)
+	case executorArrivalRate:
+		return sc.runArrivalRate(ctx)
+	case executorRamping:
+		return sc.runRamping(ctx)
+	default:
+		return sc.runLockstep(ctx)
+	}
+}
+
+func (lt *DataTest) scenarioByName(name string) *Scenario {
+	for _, sc := range lt.scenarios {
+		if sc.name == name {
+			return sc
+		}
+	}
+
+	return nil
+}
+
+func (lt *DataTest) hasScenarios() bool {
+	return len(lt.Scenarios) > 0
+}
+
+// preloadScenarios prepares the scenarios to run, sorted by name. Without
+// "scenarios", the root of the test file is the only scenario.
+func (lt *DataTest) preloadScenarios() error {
+	if !lt.hasScenarios() {
+		lt.Scenario.name = defaultScenario
+		lt.Scenario.test = lt
+		lt.scenarios = []*Scenario{&lt.Scenario}
+
+		return lt.Scenario.preload()
+	}
+
+	names := make([]string, 0, len(lt.Scenarios))
+	for name := range lt.Scenarios {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	weights := make([]float64, len(names))
+	for i, name := range names {
+		sc := lt.Scenarios[name]
+		if sc == nil {
+			return fmt.Errorf("scenarios[%s] cannot be empty", name)
+		}
+
+		if sc.Weight < 0 {
+			return fmt.Errorf("scenarios[%s].weight cannot be negative", name)
+		}
+
+		if sc.Weight == 0 {
+			sc.Weight = 1
+		}
+
+		weights[i] = sc.Weight
+	}
+
+	lt.scenarios = make([]*Scenario, 0, len(names))
+	for i, name := range names {
+		if name == "" {
+			return errors.New("the scenario name cannot be empty")
+		}
+
+		sc := lt.Scenarios[name]
+		sc.name = name
+		sc.test = lt
+
+		part := func(value int) int {
+			return splitByWeight(value, weights)[i]
+		}
+
+		if err := sc.inherit(&lt.Scenario, part); err != nil {
+			return err
+		}
+
+		if err := sc.preload(); err != nil {
+			return err
+		}
+
+		lt.scenarios = append(lt.scenarios, sc)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitByWeight(t *testing.T) {
+	tests := []struct {
+		value    int
+		weights  []float64
+		expected []int
+	}{
+		{10, []float64{70, 25, 5}, []int{7, 2, 1}},
+		{100, []float64{70, 25, 5}, []int{70, 25, 5}},
+		{10, []float64{1, 1, 1}, []int{4, 3, 3}},
+		{2, []float64{1, 1, 1}, []int{1, 1, 0}},
+		{3, []float64{98, 1, 1}, []int{1, 1, 1}},
+		{5, []float64{1, 1, 1, 1, 100}, []int{1, 1, 1, 1, 1}},
+		{7, []float64{1}, []int{7}},
+		{1, []float64{0.5, 0.5}, []int{1, 0}},
+		{0, []float64{1, 1}, []int{0, 0}},
+		{-1, []float64{1, 1}, []int{-1, -1}},
+	}
+
+	for _, tt := range tests {
+		parts := splitByWeight(tt.value, tt.weights)
+
+		sum := 0
+		for _, part := range parts {
+			sum += part
+		}
+
+		if len(parts) != len(tt.expected) {
+			t.Fatalf("%d %v: got %v, expected %v", tt.value, tt.weights, parts, tt.expected)
+		}
+
+		for i := range parts {
+			if parts[i] != tt.expected[i] {
+				t.Errorf("%d %v: got %v, expected %v", tt.value, tt.weights, parts, tt.expected)
+				break
+			}
+		}
+
+		if tt.value > 0 && sum != tt.value {
+			t.Errorf("%d %v: the parts add up to %d", tt.value, tt.weights, sum)
+		}
+	}
+
+	for value := 4; value <= 200; value++ {
+		parts := splitByWeight(value, []float64{0.7, 0.2, 0.07, 0.03})
+
+		sum := 0
+		for _, part := range parts {
+			if part < 1 {
+				t.Errorf("%d: got %v, expected every part to be at least 1", value, parts)
+			}
+
+			sum += part
+		}
+
+		if sum != value {
+			t.Errorf("%d: got %v, which adds up to %d", value, parts, sum)
+		}
+	}
+}
+
+func TestInheritRequiresEnoughWorkers(t *testing.T) {
+	lt := new(DataTest)
+	lt.Scenario.Parallel = 2
+	lt.Scenarios = map[string]*Scenario{"a": {}, "b": {}, "c": {}}
+
+	err := lt.preloadScenarios()
+	if err == nil || !strings.Contains(err.Error(), `the "parallel" (2) of the root is less than the number of scenarios`) {
+		t.Errorf("got %v, expected an error for the parallel", err)
+	}
+}
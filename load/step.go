@@ -17,59 +17,68 @@ limitations under the License.
 package load
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"github.com/gabriellasaro/load-test/metrics"
+	"github.com/gabriellasaro/load-test/template"
 	"github.com/gabriellasaro/load-test/types"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"os"
 	"strings"
 	"time"
 )
 
+const (
+	durationModeHeaders = "HEADERS"
+	durationModeTotal   = "TOTAL"
+)
+
 type Step struct {
-	ConditionRaw  *types.Str `json:"if"`
-	condition     *Condition
-	URL           types.Str      `json:"url"`
-	ContentType   types.Str      `json:"content_type"`
-	Method        types.Str      `json:"method"`
-	Header        []Variable     `json:"header"`
-	Timeout       *time.Duration `json:"timeout"`
-	BodyJSON      interface{}    `json:"body_json"`
-	Body          types.Str      `json:"body"`
-	BodyLoadFile  string         `json:"body_load_file"`
-	index         int
-	preloadedBody string
-	response      *ResponseCycle
+	Name         types.Str  `json:"name"`
+	ConditionRaw *types.Str `json:"if"`
+	condition    *Condition
+	URL          types.Str             `json:"url"`
+	ContentType  types.Str             `json:"content_type"`
+	Method       types.Str             `json:"method"`
+	Header       []Variable            `json:"header"`
+	Timeout      *time.Duration        `json:"timeout"`
+	ThinkTime    *ThinkTime            `json:"think_time"`
+	DurationMode types.Str             `json:"duration_mode"`
+	ClearCookies bool                  `json:"clear_cookies"`
+	BodyJSON     interface{}           `json:"body_json"`
+	Body         types.Str             `json:"body"`
+	BodyLoadFile string                `json:"body_load_file"`
+	Extract      map[string]Extraction `json:"extract"`
+	index        int
+	cycle        *Cycle
+	url          *template.Template
+	headers      []*template.Template
+	body         *template.Template
+	bodyJSON     interface{}
+	encoders     map[*template.Tag]encoder
 }
 
-func (s *Step) applyVariables(variables []*Variable, cycle *[]*Step, data string) (string, error) {
-	data = types.ReplaceKeyByValue(variables, data)
-
-	env, err := getEnvironmentVariables(data)
-	if err != nil {
-		return "", err
+func (s *Step) durationMode() string {
+	mode := s.DurationMode.TrimSpace().ToUpper()
+	if mode.IsEmpty() {
+		return durationModeHeaders
 	}
-	data = types.ReplaceKeyByValue(env, data)
 
-	paths, err := getPathVariables(s.index, data, cycle)
-	if err != nil {
-		return "", err
-	}
-	data = types.ReplaceKeyByValue(paths, data)
+	return mode.String()
+}
 
-	resp, err := getResponseVariables(s.index, cycle, data)
-	if err != nil {
-		return "", err
+// measuredDuration is the duration that counts toward the averages and the
+// thresholds: until the headers are received, or including the body.
+func (s *Step) measuredDuration(response *ResponseCycle) time.Duration {
+	if s.durationMode() == durationModeTotal {
+		return response.TotalDuration
 	}
-	data = types.ReplaceKeyByValue(resp, data)
-
-	return data, nil
-}
 
-func (s *Step) getURL(variables []*Variable, cycle *[]*Step) (string, error) {
-	return s.applyVariables(variables, cycle, s.URL.TrimSpace().String())
+	return response.Duration
 }
 
 func (s *Step) getMethod() string {
@@ -80,31 +89,59 @@ func (s *Step) getContentType() string {
 	return s.ContentType.ToUpper().String()
 }
 
+// preloadBody parses the body of the step: body, body_json or the content of
+// body_load_file.
 func (s *Step) preloadBody() error {
 	body := s.Body.TrimSpace().String()
-	if len(body) > 0 {
-		s.preloadedBody = body
-	} else if s.BodyJSON != nil {
-		content, err := json.Marshal(s.BodyJSON)
+
+	if len(body) == 0 && s.BodyJSON != nil {
+		bodyJSON, err := s.parseJSONTemplate("body_json", s.BodyJSON)
 		if err != nil {
 			return err
 		}
 
-		s.preloadedBody = string(content)
-	} else if len(s.BodyLoadFile) > 0 {
+		s.bodyJSON = bodyJSON
+
+		return nil
+	} else if len(body) == 0 && len(s.BodyLoadFile) > 0 {
 		content, err := os.ReadFile(s.BodyLoadFile)
 		if err != nil {
 			return err
 		}
 
-		s.preloadedBody = string(content)
+		body = string(content)
+	}
+
+	t, err := s.parseTemplate("body", body)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(s.getContentType(), "JSON") {
+		s.encodeJSONBody(t)
 	}
 
+	s.body = t
+
 	return nil
 }
 
-func (s *Step) getBodyReader(variables []*Variable, cycles *[]*Step) (io.Reader, error) {
-	body, err := s.applyVariables(variables, cycles, s.preloadedBody)
+func (s *Step) getBodyReader(it *iteration) (io.Reader, error) {
+	if s.bodyJSON != nil {
+		value, err := s.renderJSON(s.bodyJSON, it)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(content), nil
+	}
+
+	body, err := s.render(s.body, it)
 	if err != nil {
 		return nil, err
 	}
@@ -112,8 +149,12 @@ func (s *Step) getBodyReader(variables []*Variable, cycles *[]*Step) (io.Reader,
 	return strings.NewReader(body), nil
 }
 
-func (s *Step) preload(index int) error {
+// preload validates the step and parses its templates once; the step is then
+// shared by all workers of the scenario.
+func (s *Step) preload(index int, cycle *Cycle) error {
 	s.index = index
+	s.cycle = cycle
+	s.encoders = make(map[*template.Tag]encoder)
 
 	if err := s.preloadIf(); err != nil {
 		return err
@@ -123,42 +164,88 @@ func (s *Step) preload(index int) error {
 		return fmt.Errorf("cycle[%d].url cannot be empty", index)
 	}
 
+	url, err := s.parseTemplate("url", s.URL.TrimSpace().String())
+	if err != nil {
+		return err
+	}
+
+	s.encodeURL(url)
+	s.url = url
+
+	s.headers = make([]*template.Template, len(s.Header))
+	for i, item := range s.Header {
+		header, err := s.parseTemplate(fmt.Sprintf("header[%d]", i), item.Value())
+		if err != nil {
+			return err
+		}
+
+		s.encodeHeader(header)
+		s.headers[i] = header
+	}
+
 	if len(s.getMethod()) == 0 {
 		s.Method = "GET"
 	}
 
 	if s.getMethod() != "GET" && s.BodyJSON == nil && s.getContentType() == "" {
-		return errors.New("for your request type it is necessary to inform the content_type")
+		return fmt.Errorf("cycle[%d]: for your request type it is necessary to inform the content_type", index)
 	} else if s.getContentType() == "" && s.BodyJSON != nil {
 		s.ContentType = "application/json"
 	}
 
-	err := s.preloadBody()
-	if err != nil {
+	switch s.durationMode() {
+	case durationModeHeaders, durationModeTotal:
+	default:
+		return fmt.Errorf("cycle[%d].duration_mode (%s) is not valid", index, s.DurationMode)
+	}
+
+	if s.ThinkTime != nil {
+		if err := s.ThinkTime.validate(); err != nil {
+			return fmt.Errorf("cycle[%d].%s", index, err.Error())
+		}
+	}
+
+	if err := s.validateExtract(); err != nil {
 		return err
 	}
 
-	return nil
+	return s.preloadBody()
 }
 
-func (s *Step) execute(variables []*Variable, cycles *[]*Step) error {
-	if err := s.executeIf(variables, cycles); err != nil {
-		return fmt.Errorf("condition (%s) is not satisfied: %s", s.ConditionRaw, err.Error())
+func (s *Step) execute(ctx context.Context, it *iteration) error {
+	state := &it.steps[s.index]
+
+	if s.ClearCookies {
+		it.session.resetCookies()
 	}
 
-	url, err := s.getURL(variables, cycles)
+	if err := s.executeIf(it); err != nil {
+		return &conditionError{condition: s.ConditionRaw.String(), err: err}
+	}
+
+	url, err := s.render(s.url, it)
 	if err != nil {
-		return err
+		return fmt.Errorf("cycle[%d].url: %s", s.index, err.Error())
 	}
 
-	body, err := s.getBodyReader(variables, cycles)
+	body, err := s.getBodyReader(it)
 	if err != nil {
-		return err
+		return fmt.Errorf("cycle[%d].body: %s", s.index, err.Error())
 	}
 
+	if s.Timeout != nil {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, time.Second*(*s.Timeout))
+		defer cancel()
+	}
+
+	trace := new(requestTrace)
+	ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+
 	timeStart := time.Now()
 
-	req, err := http.NewRequest(s.getMethod(), url, body)
+	req, err := http.NewRequestWithContext(ctx, s.getMethod(), url, body)
 	if err != nil {
 		return fmt.Errorf("cycle[%d]: %s", s.index, err.Error())
 	}
@@ -167,25 +254,33 @@ func (s *Step) execute(variables []*Variable, cycles *[]*Step) error {
 		req.Header.Set("Content-Type", s.getContentType())
 	}
 
-	for _, item := range s.Header {
-		req.Header.Set(item.Key(), item.Value())
-	}
+	for i, item := range s.Header {
+		value, err := s.render(s.headers[i], it)
+		if err != nil {
+			return fmt.Errorf("cycle[%d].header[%d]: %s", s.index, i, err.Error())
+		}
 
-	client := &http.Client{}
-	if s.Timeout != nil {
-		client.Timeout = time.Second * (*s.Timeout)
+		req.Header.Set(item.Key(), value)
 	}
 
-	resp, err := client.Do(req)
+	state.requested = true
+
+	it.session.visit(req.URL)
+
+	resp, err := it.session.client.Do(req)
 	if err != nil {
 		return err
 	}
 
 	defer resp.Body.Close()
 
+	state.statusCode = resp.StatusCode
+
 	responseCycle := new(ResponseCycle)
 	responseCycle.StatusCode = resp.StatusCode
 	responseCycle.URL = url
+	responseCycle.FinalURL = resp.Request.URL.String()
+	responseCycle.Header = resp.Header
 	responseCycle.Duration = time.Since(timeStart)
 
 	responseBody, err := io.ReadAll(resp.Body)
@@ -193,20 +288,33 @@ func (s *Step) execute(variables []*Variable, cycles *[]*Step) error {
 		return err
 	}
 
+	trace.set(&trace.bodyDone)
+
+	responseCycle.TotalDuration = time.Since(timeStart)
+	responseCycle.Size = int64(len(responseBody))
+	responseCycle.ContentLength = resp.ContentLength
+	if responseCycle.ContentLength < 0 {
+		responseCycle.ContentLength = responseCycle.Size
+	}
 	responseCycle.Body = responseBody
-	s.response = responseCycle
+	responseCycle.Timings = trace.timings()
+	state.response = responseCycle
 
-	return nil
+	return s.extract(responseCycle, it.session)
 }
 
-func (s *Step) responseDataToLog(index int, err error) string {
-	data := fmt.Sprintf("STEP: %d\n", index)
+func (s *Step) responseDataToLog(state *stepState, err error) string {
+	data := fmt.Sprintf("STEP: %d\n", s.index)
+	if !s.Name.IsEmpty() {
+		data = fmt.Sprintf("STEP: %d (%s)\n", s.index, s.Name)
+	}
 
-	if s.response != nil {
-		data += fmt.Sprintf("\tURL: %s\n", s.response.URL)
+	if response := state.response; response != nil {
+		data += fmt.Sprintf("\tURL: %s\n", response.URL)
 		data += fmt.Sprintf("\tMETHOD: %s | CONTENT-TYPE: %s\n", s.getMethod(), s.getContentType())
-		data += fmt.Sprintf("\tSTATUS CODE: %d\n", s.response.StatusCode)
-		data += fmt.Sprintf("\tDURATION: %s\n", s.response.Duration)
+		data += fmt.Sprintf("\tSTATUS CODE: %d\n", response.StatusCode)
+		data += fmt.Sprintf("\tDURATION: %s | TOTAL DURATION: %s | SIZE: %d bytes\n", response.Duration, response.TotalDuration, response.Size)
+		data += fmt.Sprintf("\tPHASES: %s\n", response.Timings.String())
 	}
 
 	if err != nil {
@@ -216,18 +324,19 @@ func (s *Step) responseDataToLog(index int, err error) string {
 	return data
 }
 
-func (s *Step) addDuration(loop int) {
-	if s.response != nil {
-		durationMetrics.AddDuration(loop, s.index, s.response.Duration.Milliseconds())
-	}
-}
+func (s *Step) addDuration(durationMetrics *metrics.Metrics, loop int, state *stepState) {
+	if response := state.response; response != nil {
+		durationMetrics.AddDuration(loop, s.index, s.measuredDuration(response))
+		durationMetrics.AddBytes(s.index, response.Size)
 
-func getStepByIndex(cycle *[]*Step, index int) (*Step, error) {
-	for _, step := range *cycle {
-		if step.index == index {
-			return step, nil
+		for phase, duration := range response.Timings.byPhase() {
+			durationMetrics.AddPhase(s.index, phase, duration)
 		}
 	}
+}
 
-	return nil, fmt.Errorf("cycle[%d] not found", index)
+func (s *Step) addRequest(requestMetrics *metrics.Metrics, state *stepState) {
+	if state.requested {
+		requestMetrics.AddRequest(s.index, state.statusCode)
+	}
 }
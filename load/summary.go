@@ -0,0 +1,216 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"fmt"
+	"github.com/gabriellasaro/load-test/metrics"
+	"github.com/gabriellasaro/load-test/report"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const outputJSON = "json"
+
+// Options of the execution of a test file.
+type Options struct {
+	// Outputs in the format "type=destination", e.g. "json=summary.json".
+	Outputs []string
+}
+
+type output struct {
+	kind        string
+	destination string
+}
+
+func parseOutputs(outputs []string) ([]output, error) {
+	parsed := make([]output, 0, len(outputs))
+
+	for _, value := range outputs {
+		kind, destination, found := strings.Cut(value, "=")
+		if !found || strings.TrimSpace(destination) == "" {
+			return nil, fmt.Errorf("output (%s) must be in the format type=destination", value)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+		case outputJSON:
+			parsed = append(parsed, output{kind: outputJSON, destination: strings.TrimSpace(destination)})
+		default:
+			return nil, fmt.Errorf("output type (%s) is not valid", kind)
+		}
+	}
+
+	return parsed, nil
+}
+
+func summaryOfRequests(r *metrics.Requests) report.Requests {
+	statusCodes := make(map[string]int64, len(r.StatusCodes()))
+	for code, total := range r.StatusCodes() {
+		statusCodes[strconv.Itoa(code)] = total
+	}
+
+	return report.Requests{
+		Count:       r.Requests(),
+		Rate:        r.Rate(),
+		Bytes:       r.Bytes(),
+		Errors:      r.ErrorCount(),
+		ErrorRate:   r.ErrorRate(),
+		ErrorKinds:  r.Errors(),
+		StatusCodes: statusCodes,
+	}
+}
+
+func summaryOfStatistics(st *metrics.Statistics) report.Statistics {
+	return report.Statistics{
+		Count:  st.Count(),
+		Min:    report.Milliseconds(st.Min()),
+		Max:    report.Milliseconds(st.Max()),
+		Mean:   report.Milliseconds(st.Mean()),
+		StdDev: report.Milliseconds(st.StdDev()),
+		P50:    report.Milliseconds(st.P50()),
+		P90:    report.Milliseconds(st.P90()),
+		P95:    report.Milliseconds(st.P95()),
+		P99:    report.Milliseconds(st.P99()),
+		P999:   report.Milliseconds(st.P999()),
+	}
+}
+
+func summaryOfHistogram(h *metrics.Histogram) report.Statistics {
+	return report.Statistics{
+		Count:  h.Count(),
+		Min:    report.Milliseconds(h.Min()),
+		Max:    report.Milliseconds(h.Max()),
+		Mean:   report.Milliseconds(h.Mean()),
+		StdDev: report.Milliseconds(h.StdDev()),
+		P50:    report.Milliseconds(h.Percentile(50)),
+		P90:    report.Milliseconds(h.Percentile(90)),
+		P95:    report.Milliseconds(h.Percentile(95)),
+		P99:    report.Milliseconds(h.Percentile(99)),
+		P999:   report.Milliseconds(h.Percentile(99.9)),
+	}
+}
+
+func (sc *Scenario) summary() report.Scenario {
+	steps := make(map[int]*report.Step)
+
+	step := func(index string) *report.Step {
+		i, _ := strconv.Atoi(index)
+		if _, found := steps[i]; !found {
+			steps[i] = &report.Step{
+				Index:    i,
+				Requests: summaryOfRequests(metrics.NewRequests()),
+			}
+		}
+
+		return steps[i]
+	}
+
+	for _, st := range sc.metrics.StatisticsOfSteps() {
+		summaryStep := step(st.Index())
+		summaryStep.Duration = summaryOfStatistics(&st)
+		summaryStep.Phases = make(map[string]report.Statistics, len(phases))
+
+		for _, phase := range phases {
+			summaryStep.Phases[phase] = summaryOfHistogram(sc.metrics.HistogramOfPhase(summaryStep.Index, phase))
+		}
+	}
+
+	for _, r := range sc.metrics.RequestsOfSteps() {
+		step(r.Index()).Requests = summaryOfRequests(r)
+	}
+
+	summary := report.Scenario{
+		Name:               sc.name,
+		Executor:           strings.ToLower(sc.executor()),
+		Start:              sc.metrics.StartedAt(),
+		End:                sc.metrics.StoppedAt(),
+		Steps:              make([]report.Step, 0, len(steps)),
+		Totals:             summaryOfRequests(sc.metrics.TotalRequests()),
+		DataErrors:         sc.metrics.DataErrors(),
+		Interrupted:        sc.metrics.Interrupted(),
+		TruncatedLoopSteps: sc.metrics.TruncatedLoopSteps(),
+	}
+
+	for i := 0; len(summary.Steps) < len(steps); i++ {
+		if s, found := steps[i]; found {
+			summary.Steps = append(summary.Steps, *s)
+		}
+	}
+
+	if sc.executor() == executorArrivalRate {
+		summary.Arrivals = &report.Arrivals{
+			Started: sc.arrivals.started,
+			Dropped: sc.arrivals.dropped,
+		}
+	}
+
+	return summary
+}
+
+func (lt *DataTest) summary(filename string, content []byte, start, end time.Time, results []thresholdResult) *report.Summary {
+	summary := &report.Summary{
+		SchemaVersion: report.SchemaVersion,
+		File:          filename,
+		RunID:         lt.runID,
+		Start:         start,
+		End:           end,
+		Config:        content,
+		Scenarios:     make([]report.Scenario, len(lt.scenarios)),
+	}
+
+	total := metrics.NewRequests()
+	for i, sc := range lt.scenarios {
+		summary.Scenarios[i] = sc.summary()
+		total.Add(sc.metrics.TotalRequests())
+	}
+
+	summary.Totals = summaryOfRequests(total)
+	summary.Totals.Rate = float64(summary.Totals.Count) / end.Sub(start).Seconds()
+
+	summary.ConnectionsOpened = lt.HTTP.connectionsOpened()
+
+	summary.Thresholds = make([]report.Threshold, len(results))
+	for i, result := range results {
+		summary.Thresholds[i] = report.Threshold{
+			Expression:  result.threshold.Expression,
+			AbortOnFail: result.threshold.AbortOnFail,
+			Actual:      result.actual,
+			NoSamples:   result.noSamples,
+			Passed:      result.passed,
+		}
+	}
+
+	if lt.abortedBy != nil {
+		summary.AbortedBy = lt.abortedBy.Expression
+	}
+
+	return summary
+}
+
+func (lt *DataTest) writeOutputs(outputs []output, summary *report.Summary) error {
+	for _, out := range outputs {
+		switch out.kind {
+		case outputJSON:
+			if err := report.WriteJSON(out.destination, summary); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
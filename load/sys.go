@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// systemVariableNames are the names of the {%SYS:...%} variables and whether
+// their values are numbers.
+var systemVariableNames = map[string]bool{
+	"WORKER":    true,
+	"LOOP":      true,
+	"ITERATION": true,
+	"STEP":      true,
+	"ELAPSED":   true,
+	"RUN_ID":    false,
+	"SCENARIO":  false,
+}
+
+// systemVariables are the values of the {%SYS:...%} variables of an iteration.
+type systemVariables struct {
+	scenario  string
+	worker    int
+	loop      int
+	iteration int
+	start     time.Time
+	runID     string
+}
+
+func (sc *Scenario) newSystemVariables(ss *session, loop int) *systemVariables {
+	ss.iteration++
+
+	return &systemVariables{
+		scenario:  sc.name,
+		worker:    ss.worker,
+		loop:      loop,
+		iteration: ss.iteration,
+		start:     sc.test.started,
+		runID:     sc.test.runID,
+	}
+}
+
+func (sv *systemVariables) value(step int, name string) (string, error) {
+	switch name {
+	case "WORKER":
+		return strconv.Itoa(sv.worker), nil
+	case "LOOP":
+		return strconv.Itoa(sv.loop), nil
+	case "ITERATION":
+		return strconv.Itoa(sv.iteration), nil
+	case "STEP":
+		return strconv.Itoa(step), nil
+	case "ELAPSED":
+		return strconv.FormatInt(time.Since(sv.start).Milliseconds(), 10), nil
+	case "RUN_ID":
+		return sv.runID, nil
+	case "SCENARIO":
+		return sv.scenario, nil
+	default:
+		return "", fmt.Errorf("the system variable (%s) does not exist", name)
+	}
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gabriellasaro/load-test/jsonquery"
+	"github.com/gabriellasaro/load-test/template"
+	"github.com/gabriellasaro/load-test/xpath"
+	"regexp"
+	"strings"
+)
+
+const (
+	tagVar    = "VAR"
+	tagEnv    = "ENV"
+	tagPath   = "PATH"
+	tagResp   = "RESP"
+	tagRegex  = "REGEX"
+	tagBound  = "BOUND"
+	tagXPath  = "XPATH"
+	tagCookie = "COOKIE"
+	tagSys    = "SYS"
+	tagFn     = "FN"
+)
+
+var regexTagName = regexp.MustCompile(`^([A-Z]+)(?:\[([^\]]*)\])?$`)
+
+// tagName splits the first field of a tag, such as PATH[login], into the
+// name and the reference.
+func tagName(tag *template.Tag) (string, string, bool) {
+	match := regexTagName.FindStringSubmatch(strings.TrimSpace(tag.Name()))
+	if match == nil {
+		return "", "", false
+	}
+
+	return match[1], match[2], true
+}
+
+// joinFields joins the fields that may contain ":", such as a query.
+func joinFields(fields []string) string {
+	return strings.Join(fields, ":")
+}
+
+// parseTemplate parses a field of the step and validates its tags.
+func (s *Step) parseTemplate(field, source string) (*template.Template, error) {
+	return s.parseTemplateAt(field, source, 1)
+}
+
+// parseTemplateAt parses a part of a field that starts at the column.
+func (s *Step) parseTemplateAt(field, source string, column int) (*template.Template, error) {
+	t, err := template.ParseAt(source, column)
+	if err == nil {
+		err = t.Walk(s.validateTag)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cycle[%d].%s: %s", s.index, field, err.Error())
+	}
+
+	return t, nil
+}
+
+// validateTag checks the name and the fields of a tag and that the step it
+// references precedes the current step.
+func (s *Step) validateTag(tag *template.Tag) error {
+	name, reference, ok := tagName(tag)
+	if !ok {
+		return tag.Errorf("the tag (%s) is not valid", tag.Fields[0])
+	}
+
+	if _, found := filters[tag.Filter]; tag.Filter != "" && !found {
+		return tag.Errorf("the filter (%s) does not exist", tag.Filter)
+	}
+
+	fields := len(tag.Fields)
+	last, _ := tag.Fields[fields-1].Literal()
+
+	switch name {
+	case tagVar, tagEnv:
+		if fields != 3 || last != "END"+name {
+			return tag.Errorf("the tag must be {%%%s:name:END%s%%}", name, name)
+		}
+	case tagPath, tagResp, tagXPath:
+		if fields < 3 || last != "END"+name {
+			return tag.Errorf("the tag must be {%%%s[step]:...:END%s%%}", name, name)
+		}
+	case tagRegex:
+		if fields < 3 || !regexStepIndex.MatchString(last) {
+			return tag.Errorf("the tag must be {%%REGEX[step]:pattern:group%%}")
+		}
+	case tagBound:
+		if fields != 4 || last != "ENDBOUND" {
+			return tag.Errorf("the tag must be {%%BOUND[step]:left:right:ENDBOUND%%}")
+		}
+	case tagCookie:
+		if fields != 1 || reference == "" {
+			return tag.Errorf("the tag must be {%%COOKIE[name]%%}")
+		}
+
+		return nil
+	case tagSys:
+		if fields != 2 {
+			return tag.Errorf("the tag must be {%%SYS:name%%}")
+		}
+
+		if variable, ok := tag.Fields[1].Literal(); ok {
+			if _, found := systemVariableNames[variable]; !found {
+				return tag.Errorf("the system variable (%s) does not exist", variable)
+			}
+		}
+	case tagFn:
+		if fields < 2 {
+			return tag.Errorf("the tag must be {%%FN:function%%}")
+		}
+
+		if call, ok := tag.Fields[1].Literal(); ok {
+			if _, found := templateFunctions[functionName(call)]; !found {
+				return tag.Errorf("the function (%s) does not exist", functionName(call))
+			}
+		}
+	default:
+		return tag.Errorf("the tag (%s) does not exist", name)
+	}
+
+	switch name {
+	case tagPath, tagResp, tagRegex, tagBound, tagXPath:
+		index, err := s.cycle.stepIndex(reference)
+		if err != nil {
+			return tag.Errorf("%s", err.Error())
+		}
+
+		if index >= s.index {
+			return tag.Errorf("cannot use a variable that does not yet exist: cycle[%s]", reference)
+		}
+	default:
+		if reference != "" {
+			return tag.Errorf("the tag (%s) does not accept a step", name)
+		}
+	}
+
+	return compileTag(tag, name)
+}
+
+// compileTag compiles the query, the pattern, the xpath or the function call
+// of a tag whose fields have no tags, so the errors are found at load and the
+// requests do not compile them again. A function call is also run once, as
+// its arguments are only checked by the function.
+func compileTag(tag *template.Tag, name string) error {
+	fields := make([]string, len(tag.Fields))
+
+	for i, field := range tag.Fields {
+		literal, ok := field.Literal()
+		if !ok {
+			return nil
+		}
+
+		fields[i] = literal
+	}
+
+	compiled, err := compileFields(name, fields)
+	if err == nil {
+		if call, ok := compiled.(*functionCall); ok {
+			_, err = call.run()
+		}
+	}
+
+	if err != nil {
+		return tag.Errorf("%s", err.Error())
+	}
+
+	tag.Compiled = compiled
+
+	return nil
+}
+
+// compileFields compiles the query of PATH, the pattern of REGEX, the
+// expression of XPATH or the call of FN; the other tags have nothing to
+// compile.
+func compileFields(name string, fields []string) (interface{}, error) {
+	switch name {
+	case tagFn:
+		return parseFunctionCall(joinFields(fields[1:]))
+	case tagPath:
+		return jsonquery.Compile(joinFields(fields[1 : len(fields)-1]))
+	case tagRegex:
+		return compileRegex(joinFields(fields[1:]))
+	case tagXPath:
+		return xpath.Compile(joinFields(fields[1 : len(fields)-1]))
+	default:
+		return nil, nil
+	}
+}
+
+// compiled returns the value compiled at load or, when the fields have tags,
+// compiles the executed fields.
+func compiled(tag *template.Tag, name string, fields []string) (interface{}, error) {
+	if tag.Compiled != nil {
+		return tag.Compiled, nil
+	}
+
+	return compileFields(name, fields)
+}
+
+// render executes a template of the step with the values of the iteration.
+func (s *Step) render(t *template.Template, it *iteration) (string, error) {
+	return t.Execute(s.resolver(it))
+}
+
+// resolver returns the values of the tags, encoded for the place where they
+// are inserted.
+func (s *Step) resolver(it *iteration) template.Resolver {
+	return func(tag *template.Tag, fields []string) (string, error) {
+		value, err := s.resolveTag(it, tag, fields)
+		if err != nil {
+			return "", err
+		}
+
+		return s.encode(tag, value), nil
+	}
+}
+
+func (s *Step) resolveTag(it *iteration, tag *template.Tag, fields []string) (string, error) {
+	name, reference, _ := tagName(tag)
+
+	switch name {
+	case tagVar:
+		return it.variable(fields[1])
+	case tagEnv:
+		return lookupEnv(fields[1])
+	case tagCookie:
+		return it.session.cookie(reference)
+	case tagSys:
+		return it.system.value(s.index, fields[1])
+	case tagFn:
+		c, err := compiled(tag, name, fields)
+		if err != nil {
+			return "", err
+		}
+
+		return c.(*functionCall).run()
+	}
+
+	response, err := s.stepResponse(it, reference)
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case tagResp:
+		return response.getValueInResponseVariable(joinFields(fields[1 : len(fields)-1]))
+	case tagBound:
+		return response.getValueByBoundaries(fields[1], fields[2])
+	}
+
+	c, err := compiled(tag, name, fields)
+	if err != nil {
+		return "", err
+	}
+
+	switch c := c.(type) {
+	case *jsonquery.Query:
+		return response.getValueInResponseByQuery(c)
+	case *regexGroupMatch:
+		return response.matchRegex(c)
+	default:
+		return response.evaluateXPath(c.(*xpath.Expression))
+	}
+}
+
+// resolveValue returns the value of a tag with its JSON type: the value of
+// PATH, the numbers of RESP and SYS and the result of the FN functions that
+// return JSON. The other values are strings.
+func (s *Step) resolveValue(it *iteration, tag *template.Tag, fields []string) (interface{}, error) {
+	name, reference, _ := tagName(tag)
+
+	if name == tagPath {
+		response, err := s.stepResponse(it, reference)
+		if err != nil {
+			return nil, err
+		}
+
+		query, err := compiled(tag, name, fields)
+		if err != nil {
+			return nil, err
+		}
+
+		return response.searchJSON(query.(*jsonquery.Query))
+	}
+
+	value, err := s.resolveTag(it, tag, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case name == tagResp && numericResponseVariables[joinFields(fields[1:len(fields)-1])],
+		name == tagSys && systemVariableNames[fields[1]]:
+		return json.Number(value), nil
+	case name == tagFn && templateFunctions[functionName(joinFields(fields[1:]))].json:
+		return decodeJSONValue(value)
+	}
+
+	return value, nil
+}
+
+// stepResponse returns the response of a previous step of the iteration.
+func (s *Step) stepResponse(it *iteration, reference string) (*ResponseCycle, error) {
+	index, err := s.cycle.stepIndex(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return it.response(index)
+}
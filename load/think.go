@@ -0,0 +1,147 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabriellasaro/load-test/types"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	distributionFixed       = "FIXED"
+	distributionUniform     = "UNIFORM"
+	distributionNormal      = "NORMAL"
+	distributionExponential = "EXPONENTIAL"
+)
+
+// ThinkTime is a pause that simulates the user reading or typing. It can be
+// a fixed duration ("2s") or an object with a distribution:
+//
+//	{"min": "1s", "max": "3s"}                                  uniform
+//	{"distribution": "normal", "mean": "2s", "stddev": "500ms"} normal
+//	{"distribution": "exponential", "mean": "2s"}               exponential
+type ThinkTime struct {
+	Distribution types.Str      `json:"distribution,omitempty"`
+	Fixed        types.Duration `json:"fixed,omitempty"`
+	Min          types.Duration `json:"min,omitempty"`
+	Max          types.Duration `json:"max,omitempty"`
+	Mean         types.Duration `json:"mean,omitempty"`
+	StdDev       types.Duration `json:"stddev,omitempty"`
+}
+
+func (t *ThinkTime) UnmarshalJSON(data []byte) error {
+	if trimmed := strings.TrimSpace(string(data)); !strings.HasPrefix(trimmed, "{") {
+		t.Distribution = distributionFixed
+
+		return json.Unmarshal(data, &t.Fixed)
+	}
+
+	type thinkTime ThinkTime
+
+	return json.Unmarshal(data, (*thinkTime)(t))
+}
+
+func (t *ThinkTime) distribution() string {
+	distribution := t.Distribution.TrimSpace().ToUpper()
+	if !distribution.IsEmpty() {
+		return distribution.String()
+	}
+
+	if t.Min > 0 || t.Max > 0 {
+		return distributionUniform
+	}
+
+	return distributionFixed
+}
+
+func (t *ThinkTime) validate() error {
+	if t.Fixed < 0 || t.Min < 0 || t.Max < 0 || t.Mean < 0 || t.StdDev < 0 {
+		return errors.New("think_time cannot be negative")
+	}
+
+	switch t.distribution() {
+	case distributionFixed, distributionNormal, distributionExponential:
+		return nil
+	case distributionUniform:
+		if t.Max < t.Min {
+			return errors.New("think_time.max must be greater than think_time.min")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("think_time distribution (%s) is not valid", t.Distribution)
+	}
+}
+
+func (t *ThinkTime) next() time.Duration {
+	var duration float64
+
+	switch t.distribution() {
+	case distributionUniform:
+		duration = float64(t.Min) + rand.Float64()*float64(t.Max-t.Min)
+	case distributionNormal:
+		duration = float64(t.Mean) + rand.NormFloat64()*float64(t.StdDev)
+	case distributionExponential:
+		duration = rand.ExpFloat64() * float64(t.Mean)
+	default:
+		duration = float64(t.Fixed)
+	}
+
+	if duration < 0 {
+		return 0
+	}
+
+	return time.Duration(duration)
+}
+
+// pause waits for the duration or until the context is done.
+func pause(ctx context.Context, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// boundedBy shortens the duration so it does not go beyond the deadline, when
+// the deadline is not zero.
+func boundedBy(duration time.Duration, deadline time.Time) time.Duration {
+	if remaining := time.Until(deadline); !deadline.IsZero() && remaining < duration {
+		return remaining
+	}
+
+	return duration
+}
+
+// wait waits the think time, until the context is done or the deadline.
+func (t *ThinkTime) wait(ctx context.Context, deadline time.Time) {
+	if t != nil {
+		pause(ctx, boundedBy(t.next(), deadline))
+	}
+}
@@ -0,0 +1,445 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabriellasaro/load-test/metrics"
+	"github.com/gabriellasaro/load-test/types"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrThresholdsFailed is returned by Run when at least one threshold is not
+// satisfied by the final metrics.
+var ErrThresholdsFailed = errors.New("one or more thresholds have failed")
+
+// thresholdInterval is how often the thresholds with abort_on_fail are
+// evaluated during the test.
+const thresholdInterval = time.Second
+
+// defaultAbortDelay is how long after the start of the test the thresholds
+// with abort_on_fail begin to be evaluated, so the load can ramp up and the
+// metrics have samples.
+const defaultAbortDelay = 10 * time.Second
+
+var (
+	regexThreshold       = regexp.MustCompile(`^(\S+)\s*(<=|>=|==|!=|<|>)\s*(\S+)$`)
+	regexThresholdMetric = regexp.MustCompile(`^(?:scenario\[([^\]]+)\]\.)?(?:step\[([^\]]+)\]|(http_reqs|errors|duration))\.([a-z0-9_.]+)$`)
+	regexThresholdValue  = regexp.MustCompile(`^(-?[0-9]+(?:\.[0-9]+)?)(us|µs|ms|s|m|%)?$`)
+)
+
+const (
+	unitNone     = ""
+	unitDuration = "duration"
+	unitPercent  = "percent"
+)
+
+// Threshold is a pass/fail criterion evaluated against the final metrics,
+// e.g. "step[2].p95 < 300ms", "errors.rate < 1%" or "http_reqs.rate > 50".
+type Threshold struct {
+	Expression  string          `json:"threshold"`
+	AbortOnFail bool            `json:"abort_on_fail"`
+	AbortDelay  *types.Duration `json:"abort_delay,omitempty"`
+	scenario    string
+	step        int
+	group       string
+	stat        string
+	operator    string
+	value       float64
+	unit        string
+}
+
+func (t *Threshold) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Expression); err == nil {
+		return nil
+	}
+
+	type threshold Threshold
+
+	return json.Unmarshal(data, (*threshold)(t))
+}
+
+// unitOfStat returns the unit of the statistic, or an error if it does not
+// exist for the group of the metric.
+func unitOfStat(group, stat string) (string, error) {
+	switch group {
+	case "step":
+		switch stat {
+		case "min", "max", "mean", "avg", "stddev", "p50", "p90", "p95", "p99", "p99.9":
+			return unitDuration, nil
+		case "count", "reqs", "rate", "errors":
+			return unitNone, nil
+		case "error_rate":
+			return unitPercent, nil
+		}
+	case "duration":
+		switch stat {
+		case "min", "max", "mean", "avg", "stddev", "p50", "p90", "p95", "p99", "p99.9":
+			return unitDuration, nil
+		case "count":
+			return unitNone, nil
+		}
+	case "http_reqs":
+		switch stat {
+		case "count", "rate":
+			return unitNone, nil
+		}
+	case "errors":
+		switch stat {
+		case "count":
+			return unitNone, nil
+		case "rate":
+			return unitPercent, nil
+		}
+	}
+
+	return "", fmt.Errorf("metric (%s.%s) is not valid", group, stat)
+}
+
+// parseThresholdValue returns durations in milliseconds and percentages as
+// fractions (0-1).
+func parseThresholdValue(raw, unit string) (float64, error) {
+	match := regexThresholdValue.FindStringSubmatch(raw)
+	if match == nil {
+		return 0, fmt.Errorf("value (%s) is not valid", raw)
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch match[2] {
+	case "":
+		return value, nil
+	case "%":
+		if unit != unitPercent {
+			return 0, fmt.Errorf("value (%s) cannot be a percentage", raw)
+		}
+
+		return value / 100, nil
+	default:
+		if unit != unitDuration {
+			return 0, fmt.Errorf("value (%s) cannot be a duration", raw)
+		}
+
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, err
+		}
+
+		return float64(duration.Microseconds()) / 1000, nil
+	}
+}
+
+func (t *Threshold) preload(lt *DataTest) error {
+	match := regexThreshold.FindStringSubmatch(strings.TrimSpace(t.Expression))
+	if match == nil {
+		return fmt.Errorf("threshold (%s) must be in the format: metric operator value", t.Expression)
+	}
+
+	metric := regexThresholdMetric.FindStringSubmatch(match[1])
+	if metric == nil {
+		return fmt.Errorf("threshold (%s): metric (%s) is not valid", t.Expression, match[1])
+	}
+
+	t.scenario = metric[1]
+	t.group = metric[3]
+	t.stat = metric[4]
+	t.operator = match[2]
+
+	if metric[2] != "" {
+		t.group = "step"
+
+		if t.scenario == "" && lt.hasScenarios() {
+			return fmt.Errorf("threshold (%s): inform the scenario of the step, e.g. scenario[name].%s", t.Expression, match[1])
+		}
+	}
+
+	if t.scenario != "" && lt.scenarioByName(t.scenario) == nil {
+		return fmt.Errorf("threshold (%s): scenario (%s) not found", t.Expression, t.scenario)
+	}
+
+	if t.group == "step" {
+		step, err := t.scenarios(lt)[0].cycle.stepIndex(metric[2])
+		if err != nil {
+			return fmt.Errorf("threshold (%s): step (%s) not found in the cycle", t.Expression, metric[2])
+		}
+
+		t.step = step
+	}
+
+	unit, err := unitOfStat(t.group, t.stat)
+	if err != nil {
+		return fmt.Errorf("threshold (%s): %s", t.Expression, err.Error())
+	}
+	t.unit = unit
+
+	value, err := parseThresholdValue(match[3], unit)
+	if err != nil {
+		return fmt.Errorf("threshold (%s): %s", t.Expression, err.Error())
+	}
+	t.value = value
+
+	if t.AbortDelay != nil && *t.AbortDelay < 0 {
+		return fmt.Errorf("threshold (%s): abort_delay cannot be negative", t.Expression)
+	}
+
+	return nil
+}
+
+func (t *Threshold) abortDelay() time.Duration {
+	if t.AbortDelay == nil {
+		return defaultAbortDelay
+	}
+
+	return t.AbortDelay.Duration()
+}
+
+func durationOfStat(h *metrics.Histogram, stat string) float64 {
+	var value time.Duration
+
+	switch stat {
+	case "min":
+		value = h.Min()
+	case "max":
+		value = h.Max()
+	case "mean", "avg":
+		value = h.Mean()
+	case "stddev":
+		value = h.StdDev()
+	default:
+		percentage, _ := strconv.ParseFloat(strings.TrimPrefix(stat, "p"), 64)
+		value = h.Percentile(percentage)
+	}
+
+	return float64(value.Microseconds()) / 1000
+}
+
+// scenarios returns the scenarios measured by the threshold.
+func (t *Threshold) scenarios(lt *DataTest) []*Scenario {
+	if t.scenario != "" {
+		return []*Scenario{lt.scenarioByName(t.scenario)}
+	}
+
+	return lt.scenarios
+}
+
+// actual returns the current value of the metric of the threshold. It
+// returns false when the metric has no samples, such as the durations of a
+// step without responses or the error rate without executions.
+func (t *Threshold) actual(lt *DataTest) (float64, bool) {
+	scenarios := t.scenarios(lt)
+
+	if t.group == "step" {
+		sc := scenarios[0]
+		r := sc.metrics.RequestsOfStep(t.step)
+
+		switch t.stat {
+		case "count", "reqs":
+			return float64(r.Requests()), true
+		case "rate":
+			return r.Rate(), true
+		case "errors":
+			return float64(r.ErrorCount()), true
+		case "error_rate":
+			return r.ErrorRate(), r.Executions() > 0
+		default:
+			h := sc.metrics.HistogramOfStep(t.step)
+
+			return durationOfStat(h, t.stat), h.Count() > 0
+		}
+	}
+
+	if t.group == "duration" {
+		h := metrics.NewHistogram()
+		for _, sc := range scenarios {
+			h.Merge(sc.metrics.Histogram())
+		}
+
+		if t.stat == "count" {
+			return float64(h.Count()), true
+		}
+
+		return durationOfStat(h, t.stat), h.Count() > 0
+	}
+
+	total := metrics.NewRequests()
+	elapsed := time.Duration(0)
+
+	for _, sc := range scenarios {
+		total.Add(sc.metrics.TotalRequests())
+
+		if sc.metrics.Elapsed() > elapsed {
+			elapsed = sc.metrics.Elapsed()
+		}
+	}
+
+	switch t.group + "." + t.stat {
+	case "http_reqs.count":
+		return float64(total.Requests()), true
+	case "http_reqs.rate":
+		if elapsed <= 0 {
+			return 0, true
+		}
+
+		return float64(total.Requests()) / elapsed.Seconds(), true
+	case "errors.count":
+		return float64(total.ErrorCount()), true
+	default:
+		return total.ErrorRate(), total.Executions() > 0
+	}
+}
+
+func (t *Threshold) passed(actual float64) bool {
+	switch t.operator {
+	case "<":
+		return actual < t.value
+	case "<=":
+		return actual <= t.value
+	case ">":
+		return actual > t.value
+	case ">=":
+		return actual >= t.value
+	case "==":
+		return actual == t.value
+	default:
+		return actual != t.value
+	}
+}
+
+func (t *Threshold) format(value float64) string {
+	switch t.unit {
+	case unitDuration:
+		return time.Duration(value * float64(time.Millisecond)).String()
+	case unitPercent:
+		return fmt.Sprintf("%.2f%%", value*100)
+	default:
+		if value == math.Trunc(value) {
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+
+		return strconv.FormatFloat(value, 'f', 2, 64)
+	}
+}
+
+// thresholdResult is the outcome of a threshold. A metric without samples
+// fails, as there is nothing to compare with the value.
+type thresholdResult struct {
+	threshold *Threshold
+	actual    float64
+	noSamples bool
+	passed    bool
+}
+
+func (r thresholdResult) formatActual() string {
+	if r.noSamples {
+		return "no samples"
+	}
+
+	return r.threshold.format(r.actual)
+}
+
+func (lt *DataTest) preloadThresholds() error {
+	for i := range lt.Thresholds {
+		if err := lt.Thresholds[i].preload(lt); err != nil {
+			return fmt.Errorf("thresholds[%d]: %s", i, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (lt *DataTest) evaluateThresholds(onlyAbortOnFail bool) []thresholdResult {
+	results := make([]thresholdResult, 0, len(lt.Thresholds))
+
+	for i := range lt.Thresholds {
+		t := &lt.Thresholds[i]
+		if onlyAbortOnFail && !t.AbortOnFail {
+			continue
+		}
+
+		actual, sampled := t.actual(lt)
+		results = append(results, thresholdResult{
+			threshold: t,
+			actual:    actual,
+			noSamples: !sampled,
+			passed:    sampled && t.passed(actual),
+		})
+	}
+
+	return results
+}
+
+// watchThresholds evaluates the thresholds with abort_on_fail while the test
+// runs, after their abort_delay, and calls abort with the first one that
+// fails. A metric without samples yet does not abort the test.
+func (lt *DataTest) watchThresholds(done <-chan struct{}, abort func(*Threshold)) {
+	ticker := time.NewTicker(thresholdInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, result := range lt.evaluateThresholds(true) {
+				if !result.passed && !result.noSamples && time.Since(lt.started) >= result.threshold.abortDelay() {
+					abort(result.threshold)
+
+					return
+				}
+			}
+		}
+	}
+}
+
+func (lt *DataTest) showThresholds(results []thresholdResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	lt.sendDataToHistory("\nTHRESHOLDS", true)
+
+	for _, result := range results {
+		status := "PASS"
+		if !result.passed {
+			status = "FAIL"
+		}
+
+		lt.sendDataToHistory(
+			fmt.Sprintf("\t[%s] %s (actual: %s)", status, result.threshold.Expression, result.formatActual()),
+			true,
+		)
+	}
+}
+
+func thresholdsFailed(results []thresholdResult) bool {
+	for _, result := range results {
+		if !result.passed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"github.com/gabriellasaro/load-test/metrics"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newThresholdTest returns a test with one scenario of two steps.
+func newThresholdTest() *DataTest {
+	lt := new(DataTest)
+	lt.scenarios = []*Scenario{{
+		name:    defaultScenario,
+		metrics: metrics.NewMetrics(),
+		cycle:   &Cycle{Steps: make([]*Step, 2), names: map[string]int{"login": 0, "items": 1}},
+	}}
+
+	return lt
+}
+
+func TestParseThresholdValue(t *testing.T) {
+	tests := []struct {
+		raw      string
+		unit     string
+		expected float64
+		err      string
+	}{
+		{"300", unitDuration, 300, ""},
+		{"300ms", unitDuration, 300, ""},
+		{"1.5s", unitDuration, 1500, ""},
+		{"250us", unitDuration, 0.25, ""},
+		{"250µs", unitDuration, 0.25, ""},
+		{"2m", unitDuration, 120000, ""},
+		{"1%", unitPercent, 0.01, ""},
+		{"0.01", unitPercent, 0.01, ""},
+		{"50", unitNone, 50, ""},
+		{"-1", unitNone, -1, ""},
+		{"1%", unitDuration, 0, "cannot be a percentage"},
+		{"1s", unitPercent, 0, "cannot be a duration"},
+		{"1s", unitNone, 0, "cannot be a duration"},
+		{"1h", unitDuration, 0, "is not valid"},
+		{"abc", unitNone, 0, "is not valid"},
+		{"1.", unitNone, 0, "is not valid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw+" "+tt.unit, func(t *testing.T) {
+			value, err := parseThresholdValue(tt.raw, tt.unit)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got %v, expected an error with %q", err, tt.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != tt.expected {
+				t.Errorf("got %v, expected %v", value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestThresholdPreload(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"step[1].p95 < 300ms", ""},
+		{"step[0].error_rate <= 1%", ""},
+		{"duration.max < 1s", ""},
+		{"http_reqs.rate > 50", ""},
+		{"errors.count == 0", ""},
+		{"scenario[default].errors.rate < 1%", ""},
+		{"step[login].p95 < 300ms", ""},
+		{"scenario[default].step[items].error_rate < 1%", ""},
+		{"step[2].p95 < 300ms", "step (2) not found in the cycle"},
+		{"step[logout].p95 < 300ms", "step (logout) not found in the cycle"},
+		{"step[].p95 < 300ms", "metric (step[].p95) is not valid"},
+		{"scenario[other].errors.rate < 1%", "scenario (other) not found"},
+		{"step[0].p42 < 1s", "metric (step.p42) is not valid"},
+		{"http_reqs.p95 < 1s", "metric (http_reqs.p95) is not valid"},
+		{"latency.p95 < 1s", "metric (latency.p95) is not valid"},
+		{"errors.rate < 1s", "cannot be a duration"},
+		{"step[0].p95 300ms", "must be in the format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			threshold := &Threshold{Expression: tt.expression}
+
+			err := threshold.preload(newThresholdTest())
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, expected an error with %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestThresholdPreloadRequiresScenario(t *testing.T) {
+	lt := newThresholdTest()
+	lt.Scenarios = map[string]*Scenario{defaultScenario: lt.scenarios[0]}
+
+	threshold := &Threshold{Expression: "step[0].p95 < 1s"}
+	if err := threshold.preload(lt); err == nil || !strings.Contains(err.Error(), "inform the scenario") {
+		t.Errorf("got %v, expected the scenario to be required", err)
+	}
+}
+
+func TestThresholdStepByName(t *testing.T) {
+	lt := newThresholdTest()
+
+	threshold := &Threshold{Expression: "step[items].max < 1s"}
+	if err := threshold.preload(lt); err != nil {
+		t.Fatal(err)
+	}
+
+	if threshold.step != 1 {
+		t.Errorf("got the step %d, expected 1", threshold.step)
+	}
+}
+
+func TestThresholdPassed(t *testing.T) {
+	tests := []struct {
+		operator string
+		actual   float64
+		expected bool
+	}{
+		{"<", 1, true},
+		{"<", 2, false},
+		{"<=", 2, true},
+		{"<=", 3, false},
+		{">", 3, true},
+		{">", 2, false},
+		{">=", 2, true},
+		{">=", 1, false},
+		{"==", 2, true},
+		{"==", 1, false},
+		{"!=", 1, true},
+		{"!=", 2, false},
+	}
+
+	for _, tt := range tests {
+		threshold := &Threshold{operator: tt.operator, value: 2}
+
+		if passed := threshold.passed(tt.actual); passed != tt.expected {
+			t.Errorf("%v %s 2: got %v, expected %v", tt.actual, tt.operator, passed, tt.expected)
+		}
+	}
+}
+
+func TestEvaluateThresholdsWithoutSamples(t *testing.T) {
+	lt := newThresholdTest()
+	lt.Thresholds = []Threshold{
+		{Expression: "step[0].max < 1s"},
+		{Expression: "duration.p95 < 300ms"},
+		{Expression: "errors.rate < 1%"},
+		{Expression: "step[1].error_rate < 1%"},
+		{Expression: "step[0].count == 0"},
+		{Expression: "step[1].max < 1s"},
+	}
+
+	if err := lt.preloadThresholds(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := lt.scenarios[0].metrics
+	m.AddRequest(0, 0)
+	m.AddError(0, errorKindConnectionRefused)
+
+	expected := []struct {
+		noSamples bool
+		passed    bool
+	}{
+		{true, false},
+		{true, false},
+		{false, false},
+		{true, false},
+		{false, false},
+		{true, false},
+	}
+
+	for i, result := range lt.evaluateThresholds(false) {
+		if result.noSamples != expected[i].noSamples || result.passed != expected[i].passed {
+			t.Errorf("%s: got no samples %v and passed %v, expected %v and %v",
+				result.threshold.Expression, result.noSamples, result.passed, expected[i].noSamples, expected[i].passed)
+		}
+	}
+
+	m.AddDuration(1, 1, 200*time.Millisecond)
+
+	result := lt.evaluateThresholds(false)[5]
+	if result.noSamples || !result.passed || result.formatActual() != "200ms" {
+		t.Errorf("got %s, expected the step to pass with samples", result.formatActual())
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+const (
+	phaseDNS      = "dns"
+	phaseConnect  = "connect"
+	phaseTLS      = "tls"
+	phaseSending  = "sending"
+	phaseWaiting  = "waiting"
+	phaseDownload = "download"
+)
+
+// phases in the order they happen in a request.
+var phases = []string{phaseDNS, phaseConnect, phaseTLS, phaseSending, phaseWaiting, phaseDownload}
+
+// Timings are the durations of the phases of a request. DNS, connect and TLS
+// are zero when the connection is reused. Waiting is the time to first byte
+// after the request is written (server processing).
+type Timings struct {
+	DNS        time.Duration
+	Connect    time.Duration
+	TLS        time.Duration
+	Sending    time.Duration
+	Waiting    time.Duration
+	Download   time.Duration
+	ReusedConn bool
+}
+
+func (t *Timings) byPhase() map[string]time.Duration {
+	return map[string]time.Duration{
+		phaseDNS:      t.DNS,
+		phaseConnect:  t.Connect,
+		phaseTLS:      t.TLS,
+		phaseSending:  t.Sending,
+		phaseWaiting:  t.Waiting,
+		phaseDownload: t.Download,
+	}
+}
+
+func (t *Timings) String() string {
+	return fmt.Sprintf(
+		"DNS: %s | CONNECT: %s | TLS: %s | SENDING: %s | WAITING: %s | DOWNLOAD: %s | REUSED CONNECTION: %t",
+		t.DNS,
+		t.Connect,
+		t.TLS,
+		t.Sending,
+		t.Waiting,
+		t.Download,
+		t.ReusedConn,
+	)
+}
+
+// requestTrace records the moments of a request with httptrace. The hooks may
+// be called from different goroutines.
+type requestTrace struct {
+	mutex        sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+	reusedConn   bool
+}
+
+func (rt *requestTrace) set(moment *time.Time) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	*moment = time.Now()
+}
+
+func (rt *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			rt.set(&rt.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			rt.set(&rt.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			rt.mutex.Lock()
+			defer rt.mutex.Unlock()
+
+			// With several addresses only the first attempt is measured.
+			if rt.connectStart.IsZero() {
+				rt.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				rt.set(&rt.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			rt.set(&rt.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			rt.set(&rt.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			rt.mutex.Lock()
+			defer rt.mutex.Unlock()
+
+			rt.gotConn = time.Now()
+			rt.reusedConn = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			rt.set(&rt.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			rt.set(&rt.firstByte)
+		},
+	}
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+func (rt *requestTrace) timings() Timings {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	return Timings{
+		DNS:        between(rt.dnsStart, rt.dnsDone),
+		Connect:    between(rt.connectStart, rt.connectDone),
+		TLS:        between(rt.tlsStart, rt.tlsDone),
+		Sending:    between(rt.gotConn, rt.wroteRequest),
+		Waiting:    between(rt.wroteRequest, rt.firstByte),
+		Download:   between(rt.firstByte, rt.bodyDone),
+		ReusedConn: rt.reusedConn,
+	}
+}
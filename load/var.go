@@ -45,15 +45,12 @@ func (lt *DataTest) validateVariables() error {
 	return nil
 }
 
-func (lt *DataTest) getVariablesForReplace() []*Variable {
-	variablesForReplace := make([]*Variable, len(lt.Variables))
+func (lt *DataTest) variablesByKey() map[string]string {
+	variables := make(map[string]string, len(lt.Variables))
 
-	for i, v := range lt.Variables {
-		variablesForReplace[i] = &Variable{
-			Variable: "{%VAR:" + v.Key() + ":ENDVAR%}",
-			Data:     v.Value(),
-		}
+	for _, v := range lt.Variables {
+		variables[v.Key()] = v.Value()
 	}
 
-	return variablesForReplace
+	return variables
 }
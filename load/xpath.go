@@ -0,0 +1,54 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package load
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gabriellasaro/load-test/xpath"
+)
+
+// document parses the body as XML or HTML once per response.
+func (r *ResponseCycle) document() (*xpath.Node, error) {
+	if r.parsedDocument != nil {
+		return r.parsedDocument, nil
+	}
+
+	document, err := xpath.Parse(bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, fmt.Errorf("the response body is not valid XML/HTML: %s", err.Error())
+	}
+
+	r.parsedDocument = document
+
+	return document, nil
+}
+
+// evaluateXPath returns the text of the first node found by the expression.
+func (r *ResponseCycle) evaluateXPath(expression *xpath.Expression) (string, error) {
+	document, err := r.document()
+	if err != nil {
+		return "", err
+	}
+
+	value, found := expression.Evaluate(document)
+	if !found {
+		return "", fmt.Errorf("the xpath was not found: %q", expression.String())
+	}
+
+	return value, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// subBucketBits defines the precision of the histogram: every power of two
+// is split into 2^subBucketBits buckets, so the relative error is below 1/64.
+const (
+	subBucketBits  = 6
+	subBucketCount = 1 << subBucketBits
+)
+
+// Histogram records durations with microsecond resolution in log-linear
+// buckets, in the style of HDR histograms. Only the buckets in use are
+// allocated and their number is bounded by the range of the values, so the
+// memory does not grow with the number of records.
+type Histogram struct {
+	buckets    map[int]int64
+	count      int64
+	min        int64
+	max        int64
+	sum        float64
+	sumSquares float64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		buckets: make(map[int]int64),
+	}
+}
+
+func bucketIndex(value int64) int {
+	if value < 2*subBucketCount {
+		return int(value)
+	}
+
+	shift := bits.Len64(uint64(value)) - subBucketBits - 1
+
+	return shift*subBucketCount + int(value>>shift)
+}
+
+// bucketRange returns the lowest and highest values recorded by the bucket.
+func bucketRange(index int) (int64, int64) {
+	if index < 2*subBucketCount {
+		return int64(index), int64(index)
+	}
+
+	shift := index/subBucketCount - 1
+	mantissa := int64(index%subBucketCount + subBucketCount)
+
+	return mantissa << shift, (mantissa+1)<<shift - 1
+}
+
+func (h *Histogram) Record(value time.Duration) {
+	us := value.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+
+	if h.count == 0 || us < h.min {
+		h.min = us
+	}
+
+	if us > h.max {
+		h.max = us
+	}
+
+	h.buckets[bucketIndex(us)]++
+	h.count++
+	h.sum += float64(us)
+	h.sumSquares += float64(us) * float64(us)
+}
+
+// Merge adds the records of the other histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+
+	if other.max > h.max {
+		h.max = other.max
+	}
+
+	for index, total := range other.buckets {
+		h.buckets[index] += total
+	}
+
+	h.count += other.count
+	h.sum += other.sum
+	h.sumSquares += other.sumSquares
+}
+
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+func (h *Histogram) Min() time.Duration {
+	return time.Duration(h.min) * time.Microsecond
+}
+
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max) * time.Microsecond
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	return time.Duration(h.sum/float64(h.count)) * time.Microsecond
+}
+
+func (h *Histogram) StdDev() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	mean := h.sum / float64(h.count)
+	variance := h.sumSquares/float64(h.count) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+
+	return time.Duration(math.Sqrt(variance)) * time.Microsecond
+}
+
+// Percentile returns the value below which the percentage (0-100) of the
+// records falls.
+func (h *Histogram) Percentile(percentage float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	indexes := make([]int, 0, len(h.buckets))
+	for index := range h.buckets {
+		indexes = append(indexes, index)
+	}
+
+	sort.Ints(indexes)
+
+	rank := int64(math.Ceil(percentage / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var total int64
+	for _, index := range indexes {
+		total += h.buckets[index]
+		if total >= rank {
+			low, high := bucketRange(index)
+
+			value := low + (high-low)/2
+			if value < h.min {
+				value = h.min
+			} else if value > h.max {
+				value = h.max
+			}
+
+			return time.Duration(value) * time.Microsecond
+		}
+	}
+
+	return h.Max()
+}
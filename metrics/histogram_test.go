@@ -0,0 +1,161 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuckets(t *testing.T) {
+	values := []int64{0, 1, 63, 64, 127, 128, 129, 255, 256, 1000, 1023, 1024, 65535, 65536, 999999, 1 << 40}
+	for v := int64(1); v < 1<<24; v = v*3 + 1 {
+		values = append(values, v)
+	}
+
+	for _, value := range values {
+		index := bucketIndex(value)
+		low, high := bucketRange(index)
+
+		if value < low || value > high {
+			t.Errorf("%d: bucket %d is [%d, %d]", value, index, low, high)
+		}
+
+		if float64(high-low) > float64(value)/subBucketCount {
+			t.Errorf("%d: bucket [%d, %d] is wider than 1/%d of the value", value, low, high, subBucketCount)
+		}
+
+		if bucketIndex(value+1) < index {
+			t.Errorf("%d: the buckets are not ordered", value)
+		}
+
+		if low > 0 && bucketIndex(low-1) != index-1 {
+			t.Errorf("%d: bucket %d does not follow bucket %d", value, index, bucketIndex(low-1))
+		}
+	}
+}
+
+func histogramOf(values ...time.Duration) *Histogram {
+	h := NewHistogram()
+	for _, value := range values {
+		h.Record(value)
+	}
+
+	return h
+}
+
+func TestPercentile(t *testing.T) {
+	exact := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		exact.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	tests := []struct {
+		percentage float64
+		expected   time.Duration
+	}{
+		{0, 1 * time.Microsecond},
+		{1, 1 * time.Microsecond},
+		{50, 50 * time.Microsecond},
+		{90, 90 * time.Microsecond},
+		{99, 99 * time.Microsecond},
+		{99.9, 100 * time.Microsecond},
+		{100, 100 * time.Microsecond},
+	}
+
+	for _, tt := range tests {
+		if value := exact.Percentile(tt.percentage); value != tt.expected {
+			t.Errorf("p%v: got %s, expected %s", tt.percentage, value, tt.expected)
+		}
+	}
+
+	wide := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		wide.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	for _, percentage := range []float64{50, 90, 95, 99, 99.9} {
+		expected := time.Duration(percentage*10) * time.Millisecond
+		value := wide.Percentile(percentage)
+
+		if diff := value - expected; diff < -expected/50 || diff > expected/50 {
+			t.Errorf("p%v: got %s, expected %s within 2%%", percentage, value, expected)
+		}
+	}
+
+	if value := wide.Percentile(100); value != time.Second {
+		t.Errorf("p100: got %s, expected the max", value)
+	}
+
+	if value := NewHistogram().Percentile(50); value != 0 {
+		t.Errorf("empty: got %s, expected 0", value)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := histogramOf(5*time.Millisecond, 7*time.Millisecond, 300*time.Microsecond)
+	b := histogramOf(2*time.Millisecond, 90*time.Millisecond)
+	all := histogramOf(5*time.Millisecond, 7*time.Millisecond, 300*time.Microsecond, 2*time.Millisecond, 90*time.Millisecond)
+
+	merged := NewHistogram()
+	merged.Merge(a)
+	merged.Merge(NewHistogram())
+	merged.Merge(b)
+
+	if merged.Count() != all.Count() || merged.Min() != all.Min() || merged.Max() != all.Max() {
+		t.Errorf("got count %d, min %s, max %s, expected %d, %s, %s",
+			merged.Count(), merged.Min(), merged.Max(), all.Count(), all.Min(), all.Max())
+	}
+
+	if merged.Mean() != all.Mean() || merged.StdDev() != all.StdDev() {
+		t.Errorf("got mean %s, stddev %s, expected %s, %s", merged.Mean(), merged.StdDev(), all.Mean(), all.StdDev())
+	}
+
+	for _, percentage := range []float64{0, 25, 50, 75, 100} {
+		if merged.Percentile(percentage) != all.Percentile(percentage) {
+			t.Errorf("p%v: got %s, expected %s", percentage, merged.Percentile(percentage), all.Percentile(percentage))
+		}
+	}
+
+	if a.Count() != 3 || b.Count() != 2 {
+		t.Error("the merged histograms were changed")
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	us := time.Microsecond
+
+	tests := []struct {
+		values []time.Duration
+		mean   time.Duration
+		stdDev time.Duration
+	}{
+		{nil, 0, 0},
+		{[]time.Duration{7 * us}, 7 * us, 0},
+		{[]time.Duration{2 * us, 4 * us, 4 * us, 4 * us, 5 * us, 5 * us, 7 * us, 9 * us}, 5 * us, 2 * us},
+		{[]time.Duration{10 * time.Millisecond, 10 * time.Millisecond}, 10 * time.Millisecond, 0},
+		{[]time.Duration{-time.Second, 4 * us}, 2 * us, 2 * us},
+	}
+
+	for _, tt := range tests {
+		h := histogramOf(tt.values...)
+
+		if h.Mean() != tt.mean || h.StdDev() != tt.stdDev {
+			t.Errorf("%v: got mean %s, stddev %s, expected %s, %s", tt.values, h.Mean(), h.StdDev(), tt.mean, tt.stdDev)
+		}
+	}
+}
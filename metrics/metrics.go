@@ -18,6 +18,7 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -41,23 +42,168 @@ func (at *AverageTime) Average() time.Duration {
 	return at.average
 }
 
+// Statistics summarizes the durations of a step, or of a step in a loop.
+type Statistics struct {
+	loop   string
+	index  string
+	count  int64
+	min    time.Duration
+	max    time.Duration
+	mean   time.Duration
+	stdDev time.Duration
+	p50    time.Duration
+	p90    time.Duration
+	p95    time.Duration
+	p99    time.Duration
+	p999   time.Duration
+}
+
+func newStatistics(loop, index string, h *Histogram) Statistics {
+	return Statistics{
+		loop:   loop,
+		index:  index,
+		count:  h.Count(),
+		min:    h.Min(),
+		max:    h.Max(),
+		mean:   h.Mean(),
+		stdDev: h.StdDev(),
+		p50:    h.Percentile(50),
+		p90:    h.Percentile(90),
+		p95:    h.Percentile(95),
+		p99:    h.Percentile(99),
+		p999:   h.Percentile(99.9),
+	}
+}
+
+func (st *Statistics) Loop() string {
+	return st.loop
+}
+
+func (st *Statistics) Index() string {
+	return st.index
+}
+
+func (st *Statistics) Count() int64 {
+	return st.count
+}
+
+func (st *Statistics) Min() time.Duration {
+	return st.min
+}
+
+func (st *Statistics) Max() time.Duration {
+	return st.max
+}
+
+func (st *Statistics) Mean() time.Duration {
+	return st.mean
+}
+
+func (st *Statistics) StdDev() time.Duration {
+	return st.stdDev
+}
+
+func (st *Statistics) P50() time.Duration {
+	return st.p50
+}
+
+func (st *Statistics) P90() time.Duration {
+	return st.p90
+}
+
+func (st *Statistics) P95() time.Duration {
+	return st.p95
+}
+
+func (st *Statistics) P99() time.Duration {
+	return st.p99
+}
+
+func (st *Statistics) P999() time.Duration {
+	return st.p999
+}
+
+// MaxLoopsOfLoopSteps is the number of loops with statistics per loop; the
+// durations of the following loops count only toward the statistics of the
+// steps, so the memory does not grow with long runs.
+const MaxLoopsOfLoopSteps = 1000
+
 type Metrics struct {
-	mutex             sync.Mutex
-	durationStepsLoop map[string]int64
-	durationSteps     map[string]int64
-	totalStepsLoop    map[string]int64
-	totalSteps        map[string]int64
+	mutex              sync.Mutex
+	durationStepsLoop  map[string]*Histogram
+	durationSteps      map[string]*Histogram
+	requests           map[string]*Requests
+	phasesSteps        map[string]*Histogram
+	truncatedLoopSteps int64
+	dataErrors         int64
+	interrupted        int64
+	start              time.Time
+	end                time.Time
 }
 
 func NewMetrics() *Metrics {
 	return &Metrics{
-		durationStepsLoop: make(map[string]int64),
-		durationSteps:     make(map[string]int64),
-		totalStepsLoop:    make(map[string]int64),
-		totalSteps:        make(map[string]int64),
+		durationStepsLoop: make(map[string]*Histogram),
+		durationSteps:     make(map[string]*Histogram),
+		requests:          make(map[string]*Requests),
+		phasesSteps:       make(map[string]*Histogram),
 	}
 }
 
+// Start marks the beginning of the period used to calculate the rates.
+func (m *Metrics) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.start = time.Now()
+}
+
+// Stop marks the end of the period used to calculate the rates.
+func (m *Metrics) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.end = time.Now()
+}
+
+func (m *Metrics) StartedAt() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.start
+}
+
+func (m *Metrics) StoppedAt() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.end
+}
+
+func (m *Metrics) elapsed() time.Duration {
+	if m.start.IsZero() {
+		return 0
+	}
+
+	if m.end.IsZero() {
+		return time.Since(m.start)
+	}
+
+	return m.end.Sub(m.start)
+}
+
+func (m *Metrics) requestsOfStep(index int) *Requests {
+	key := m.keyIndex(index)
+
+	r, found := m.requests[key]
+	if !found {
+		r = newRequests(key, 0)
+		m.requests[key] = r
+	}
+
+	return r
+}
+
 func (m *Metrics) keyLoopAndIndex(worker, index int) string {
 	return fmt.Sprintf("%d-%d", worker, index)
 }
@@ -66,62 +212,296 @@ func (m *Metrics) keyIndex(index int) string {
 	return fmt.Sprintf("%d", index)
 }
 
-func (m *Metrics) AddDuration(loop, index int, value int64) {
+func record(histograms map[string]*Histogram, key string, value time.Duration) {
+	h, found := histograms[key]
+	if !found {
+		h = NewHistogram()
+		histograms[key] = h
+	}
+
+	h.Record(value)
+}
+
+func (m *Metrics) AddDuration(loop, index int, value time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if loop <= MaxLoopsOfLoopSteps {
+		record(m.durationStepsLoop, m.keyLoopAndIndex(loop, index), value)
+	} else {
+		m.truncatedLoopSteps++
+	}
+
+	record(m.durationSteps, m.keyIndex(index), value)
+
+	m.requestsOfStep(index).successes++
+}
+
+func (m *Metrics) keyIndexAndPhase(index int, phase string) string {
+	return fmt.Sprintf("%d-%s", index, phase)
+}
+
+// AddPhase records the duration of a phase of the request of the step, such
+// as DNS lookup or time to first byte.
+func (m *Metrics) AddPhase(index int, phase string, value time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	record(m.phasesSteps, m.keyIndexAndPhase(index, phase), value)
+}
+
+// HistogramOfPhase returns a copy of the durations of the phase of the step.
+func (m *Metrics) HistogramOfPhase(index int, phase string) *Histogram {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	h := NewHistogram()
+	if step, found := m.phasesSteps[m.keyIndexAndPhase(index, phase)]; found {
+		h.Merge(step)
+	}
+
+	return h
+}
+
+// AddRequest counts a request sent by the step. The status code is zero when
+// no response was received.
+func (m *Metrics) AddRequest(index, statusCode int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r := m.requestsOfStep(index)
+	r.requests++
+
+	if statusCode > 0 {
+		r.statusCodes[statusCode]++
+	}
+}
+
+// AddBytes adds the size of a response body received by the step.
+func (m *Metrics) AddBytes(index int, size int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requestsOfStep(index).bytes += size
+}
+
+// AddError counts an execution of the step that ended with the kind of error.
+func (m *Metrics) AddError(index int, kind string) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	m.durationStepsLoop[m.keyLoopAndIndex(loop, index)] += value
-	m.durationSteps[m.keyIndex(index)] += value
+	m.requestsOfStep(index).errors[kind]++
+}
 
-	m.totalStepsLoop[m.keyLoopAndIndex(loop, index)]++
-	m.totalSteps[m.keyIndex(index)]++
+// AddDataError counts an iteration that did not run because a data source
+// failed to give it a row; no request was sent.
+func (m *Metrics) AddDataError() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dataErrors++
 }
 
-func (m *Metrics) averagesOfLoopSteps(key string) time.Duration {
-	return m.average(
-		key,
-		m.durationStepsLoop,
-		m.totalStepsLoop,
-	)
+func (m *Metrics) DataErrors() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.dataErrors
 }
 
-func (m *Metrics) averagesOfStep(key string) time.Duration {
-	return m.average(
-		key,
-		m.durationSteps,
-		m.totalSteps,
-	)
+// AddInterrupted counts a cycle cut short by the end of the test, such as
+// the grace period or a threshold with abort_on_fail. It is not an error of
+// the steps.
+func (m *Metrics) AddInterrupted() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.interrupted++
 }
 
-func (m *Metrics) average(key string, values, totals map[string]int64) time.Duration {
-	return time.Duration(float64(values[key])/float64(totals[key])) * time.Millisecond
+func (m *Metrics) Interrupted() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.interrupted
 }
 
 func (m *Metrics) AveragesOfLoopSteps() []AverageTime {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	averages := make([]AverageTime, 0)
 
-	for key := range m.durationStepsLoop {
+	for key, h := range m.durationStepsLoop {
 		parts := strings.Split(key, "-")
 
 		averages = append(averages, AverageTime{
 			loop:    parts[0],
 			index:   parts[1],
-			average: m.averagesOfLoopSteps(key),
+			average: h.Mean(),
 		})
 	}
 
 	return averages
 }
 
+// TruncatedLoopSteps returns the number of durations of the loops after
+// MaxLoopsOfLoopSteps, which are not in the averages and statistics per loop.
+func (m *Metrics) TruncatedLoopSteps() int64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.truncatedLoopSteps
+}
+
 func (m *Metrics) AveragesOfSteps() []AverageTime {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	averages := make([]AverageTime, 0)
 
-	for key := range m.durationSteps {
+	for key, h := range m.durationSteps {
 		averages = append(averages, AverageTime{
 			index:   key,
-			average: m.averagesOfStep(key),
+			average: h.Mean(),
 		})
 	}
 
 	return averages
 }
+
+// StatisticsOfLoopSteps returns the statistics sorted by loop and step.
+func (m *Metrics) StatisticsOfLoopSteps() []Statistics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	statistics := make([]Statistics, 0, len(m.durationStepsLoop))
+
+	for key, h := range m.durationStepsLoop {
+		parts := strings.Split(key, "-")
+
+		statistics = append(statistics, newStatistics(parts[0], parts[1], h))
+	}
+
+	sortStatistics(statistics)
+
+	return statistics
+}
+
+// StatisticsOfSteps returns the statistics sorted by step.
+func (m *Metrics) StatisticsOfSteps() []Statistics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	statistics := make([]Statistics, 0, len(m.durationSteps))
+
+	for key, h := range m.durationSteps {
+		statistics = append(statistics, newStatistics("", key, h))
+	}
+
+	sortStatistics(statistics)
+
+	return statistics
+}
+
+func sortStatistics(statistics []Statistics) {
+	sort.Slice(statistics, func(i, j int) bool {
+		if statistics[i].loop != statistics[j].loop {
+			return lessNumeric(statistics[i].loop, statistics[j].loop)
+		}
+
+		return lessNumeric(statistics[i].index, statistics[j].index)
+	})
+}
+
+func lessNumeric(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
+
+// RequestsOfSteps returns the requests and errors sorted by step.
+func (m *Metrics) RequestsOfSteps() []*Requests {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	requests := make([]*Requests, 0, len(m.requests))
+
+	for key, r := range m.requests {
+		step := newRequests(key, m.elapsed())
+		step.Add(r)
+
+		requests = append(requests, step)
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return lessNumeric(requests[i].index, requests[j].index)
+	})
+
+	return requests
+}
+
+// TotalRequests returns the requests and errors of all steps.
+func (m *Metrics) TotalRequests() *Requests {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	total := newRequests("", m.elapsed())
+	for _, r := range m.requests {
+		total.Add(r)
+	}
+
+	return total
+}
+
+// HistogramOfStep returns a copy of the durations of the step.
+func (m *Metrics) HistogramOfStep(index int) *Histogram {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	h := NewHistogram()
+	if step, found := m.durationSteps[m.keyIndex(index)]; found {
+		h.Merge(step)
+	}
+
+	return h
+}
+
+// Histogram returns the durations of all steps.
+func (m *Metrics) Histogram() *Histogram {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	h := NewHistogram()
+	for _, step := range m.durationSteps {
+		h.Merge(step)
+	}
+
+	return h
+}
+
+// RequestsOfStep returns the requests and errors of the step.
+func (m *Metrics) RequestsOfStep(index int) *Requests {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := m.keyIndex(index)
+
+	step := newRequests(key, m.elapsed())
+	if r, found := m.requests[key]; found {
+		step.Add(r)
+	}
+
+	return step
+}
+
+// Elapsed returns the time between Start and Stop, or since Start while the
+// metrics are not stopped.
+func (m *Metrics) Elapsed() time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.elapsed()
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatisticsOfLoopSteps(t *testing.T) {
+	m := NewMetrics()
+	m.AddDuration(10, 0, 3*time.Millisecond)
+	m.AddDuration(2, 1, 2*time.Millisecond)
+	m.AddDuration(2, 0, 1*time.Millisecond)
+	m.AddDuration(2, 0, 3*time.Millisecond)
+	m.AddDuration(MaxLoopsOfLoopSteps+1, 0, 9*time.Millisecond)
+
+	statistics := m.StatisticsOfLoopSteps()
+
+	expected := []struct {
+		loop  string
+		index string
+		count int64
+		max   time.Duration
+	}{
+		{"2", "0", 2, 3 * time.Millisecond},
+		{"2", "1", 1, 2 * time.Millisecond},
+		{"10", "0", 1, 3 * time.Millisecond},
+	}
+
+	if len(statistics) != len(expected) {
+		t.Fatalf("got %d statistics, expected %d", len(statistics), len(expected))
+	}
+
+	for i, st := range statistics {
+		if st.Loop() != expected[i].loop || st.Index() != expected[i].index || st.Count() != expected[i].count || st.Max() != expected[i].max {
+			t.Errorf("%d: got loop %s, step %s, count %d, max %s", i, st.Loop(), st.Index(), st.Count(), st.Max())
+		}
+	}
+
+	if truncated := m.TruncatedLoopSteps(); truncated != 1 {
+		t.Errorf("got %d truncated durations, expected 1", truncated)
+	}
+
+	if st := m.StatisticsOfSteps()[0]; st.Count() != 4 || st.Max() != 9*time.Millisecond {
+		t.Errorf("got count %d and max %s for the step, expected all the loops", st.Count(), st.Max())
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// Requests summarizes the requests and errors of a step. The index is empty
+// for the totals of all steps.
+type Requests struct {
+	index       string
+	requests    int64
+	successes   int64
+	bytes       int64
+	errors      map[string]int64
+	statusCodes map[int]int64
+	elapsed     time.Duration
+}
+
+func newRequests(index string, elapsed time.Duration) *Requests {
+	return &Requests{
+		index:       index,
+		errors:      make(map[string]int64),
+		statusCodes: make(map[int]int64),
+		elapsed:     elapsed,
+	}
+}
+
+// NewRequests returns empty totals, to be summed with Add.
+func NewRequests() *Requests {
+	return newRequests("", 0)
+}
+
+func (r *Requests) Add(other *Requests) {
+	r.requests += other.requests
+	r.successes += other.successes
+	r.bytes += other.bytes
+
+	for kind, total := range other.errors {
+		r.errors[kind] += total
+	}
+
+	for code, total := range other.statusCodes {
+		r.statusCodes[code] += total
+	}
+}
+
+func (r *Requests) Index() string {
+	return r.index
+}
+
+// Requests returns the number of requests sent, with or without response.
+func (r *Requests) Requests() int64 {
+	return r.requests
+}
+
+// Bytes returns the size of the response bodies received.
+func (r *Requests) Bytes() int64 {
+	return r.bytes
+}
+
+// Rate returns the requests per second.
+func (r *Requests) Rate() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+
+	return float64(r.requests) / r.elapsed.Seconds()
+}
+
+func (r *Requests) ErrorCount() int64 {
+	var total int64
+	for _, value := range r.errors {
+		total += value
+	}
+
+	return total
+}
+
+// Executions returns the number of executions of the step, with success or
+// with an error.
+func (r *Requests) Executions() int64 {
+	return r.successes + r.ErrorCount()
+}
+
+// ErrorRate returns the fraction (0-1) of the executions of the step that
+// ended with an error.
+func (r *Requests) ErrorRate() float64 {
+	executions := r.Executions()
+	if executions == 0 {
+		return 0
+	}
+
+	return float64(r.ErrorCount()) / float64(executions)
+}
+
+func (r *Requests) Errors() map[string]int64 {
+	return r.errors
+}
+
+// ErrorKinds returns the kinds of error sorted by name.
+func (r *Requests) ErrorKinds() []string {
+	kinds := make([]string, 0, len(r.errors))
+	for kind := range r.errors {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+func (r *Requests) StatusCodes() map[int]int64 {
+	return r.statusCodes
+}
+
+// Codes returns the status codes received sorted in ascending order.
+func (r *Requests) Codes() []int {
+	codes := make([]int, 0, len(r.statusCodes))
+	for code := range r.statusCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+
+	return codes
+}
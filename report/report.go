@@ -0,0 +1,131 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package report defines the machine-readable summary of a test.
+//
+// The summary is versioned by SchemaVersion. Fields may be added without
+// changing the version; removing or changing the meaning of a field
+// increments it. All durations are in milliseconds and all rates are per
+// second.
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const SchemaVersion = 1
+
+type Summary struct {
+	SchemaVersion     int             `json:"schema_version"`
+	File              string          `json:"file"`
+	RunID             string          `json:"run_id"`
+	Start             time.Time       `json:"start"`
+	End               time.Time       `json:"end"`
+	Config            json.RawMessage `json:"config"`
+	Scenarios         []Scenario      `json:"scenarios"`
+	Totals            Requests        `json:"totals"`
+	ConnectionsOpened int64           `json:"connections_opened"`
+	Thresholds        []Threshold     `json:"thresholds"`
+	AbortedBy         string          `json:"aborted_by,omitempty"`
+}
+
+type Scenario struct {
+	Name               string    `json:"name"`
+	Executor           string    `json:"executor"`
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	Steps              []Step    `json:"steps"`
+	Totals             Requests  `json:"totals"`
+	DataErrors         int64     `json:"data_errors"`
+	Interrupted        int64     `json:"interrupted"`
+	TruncatedLoopSteps int64     `json:"truncated_loop_steps"`
+	Arrivals           *Arrivals `json:"arrivals,omitempty"`
+}
+
+// Step statistics. Phases are keyed by dns, connect, tls, sending, waiting
+// (time to first byte) and download.
+type Step struct {
+	Index    int                   `json:"index"`
+	Duration Statistics            `json:"duration"`
+	Phases   map[string]Statistics `json:"phases,omitempty"`
+	Requests Requests              `json:"requests"`
+}
+
+// Statistics of the durations of the successful executions of a step.
+type Statistics struct {
+	Count  int64   `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	P999   float64 `json:"p99_9"`
+}
+
+type Requests struct {
+	Count       int64            `json:"count"`
+	Rate        float64          `json:"rate"`
+	Bytes       int64            `json:"bytes"`
+	Errors      int64            `json:"errors"`
+	ErrorRate   float64          `json:"error_rate"`
+	ErrorKinds  map[string]int64 `json:"error_kinds"`
+	StatusCodes map[string]int64 `json:"status_codes"`
+}
+
+// Threshold outcome. Actual is in the unit of the metric: milliseconds for
+// durations, a fraction (0-1) for error rates and a number otherwise. A
+// metric without samples has no actual value and fails.
+type Threshold struct {
+	Expression  string  `json:"threshold"`
+	AbortOnFail bool    `json:"abort_on_fail"`
+	Actual      float64 `json:"actual"`
+	NoSamples   bool    `json:"no_samples"`
+	Passed      bool    `json:"passed"`
+}
+
+// Arrivals of the arrival_rate executor.
+type Arrivals struct {
+	Started int `json:"started"`
+	Dropped int `json:"dropped"`
+}
+
+// Milliseconds converts the duration keeping the microseconds as decimals.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func WriteJSON(filename string, summary *Summary) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(summary); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
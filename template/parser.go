@@ -0,0 +1,161 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type parser struct {
+	source string
+	pos    int
+	filter string
+	column int
+}
+
+// filterAt returns the name of the filter of rest, "|name%}", if any.
+func filterAt(rest string) string {
+	end := strings.Index(rest, "%}")
+	if end < 2 || rest[0] != '|' {
+		return ""
+	}
+
+	for _, c := range rest[1:end] {
+		if c < 'a' || c > 'z' {
+			return ""
+		}
+	}
+
+	return rest[1:end]
+}
+
+// position returns the line and the column (in characters) of the offset.
+func (p *parser) position(offset int) (int, int) {
+	before := p.source[:offset]
+
+	line := strings.Count(before, "\n") + 1
+	if i := strings.LastIndexByte(before, '\n'); i >= 0 {
+		return line, utf8.RuneCountInString(before[i+1:]) + 1
+	}
+
+	return line, utf8.RuneCountInString(before) + p.column
+}
+
+func (p *parser) errorf(offset int, message string) error {
+	line, column := p.position(offset)
+
+	return &Error{Line: line, Column: column, Message: message}
+}
+
+// parse reads text and tags until the end of the source or, inside a tag,
+// until the ":" or "%}" that ends the field.
+func (p *parser) parse(inTag bool) (*Template, error) {
+	start := p.pos
+	t := new(Template)
+
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			t.nodes = append(t.nodes, node{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.source) {
+		rest := p.source[p.pos:]
+
+		switch {
+		case strings.HasPrefix(rest, `\{%`):
+			text.WriteString("{%")
+			p.pos += 3
+		case inTag && strings.HasPrefix(rest, `\%}`):
+			text.WriteString("%}")
+			p.pos += 3
+		case inTag && strings.HasPrefix(rest, `\:`):
+			text.WriteByte(':')
+			p.pos += 2
+		case strings.HasPrefix(rest, "{%"):
+			flush()
+
+			tag, err := p.parseTag()
+			if err != nil {
+				return nil, err
+			}
+
+			t.nodes = append(t.nodes, node{tag: tag})
+		case inTag && filterAt(rest) != "":
+			flush()
+			t.source = p.source[start:p.pos]
+			p.filter = filterAt(rest)
+			p.pos += len(p.filter) + 1
+
+			return t, nil
+		case inTag && (rest[0] == ':' || strings.HasPrefix(rest, "%}")):
+			flush()
+			t.source = p.source[start:p.pos]
+
+			return t, nil
+		default:
+			text.WriteByte(rest[0])
+			p.pos++
+		}
+	}
+
+	flush()
+	t.source = p.source[start:p.pos]
+
+	return t, nil
+}
+
+func (p *parser) parseTag() (*Tag, error) {
+	start := p.pos
+	p.pos += len("{%")
+
+	tag := new(Tag)
+	tag.Line, tag.Column = p.position(start)
+
+	for {
+		field, err := p.parse(true)
+		if err != nil {
+			return nil, err
+		}
+
+		tag.Fields = append(tag.Fields, field)
+		tag.Filter, p.filter = p.filter, ""
+
+		if p.pos >= len(p.source) {
+			return nil, p.errorf(start, "the tag is not closed with %}")
+		}
+
+		if p.source[p.pos] == ':' {
+			p.pos++
+			continue
+		}
+
+		p.pos += len("%}")
+
+		break
+	}
+
+	if name, ok := tag.Fields[0].Literal(); !ok || strings.TrimSpace(name) == "" {
+		return nil, p.errorf(start, "the tag must start with a name")
+	}
+
+	return tag, nil
+}
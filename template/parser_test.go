@@ -0,0 +1,328 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// echo resolves each tag to "(field,field...|filter)", which shows how the
+// tag was parsed.
+func echo(tag *Tag, fields []string) (string, error) {
+	value := strings.Join(fields, ",")
+	if tag.Filter != "" {
+		value += "|" + tag.Filter
+	}
+
+	return "(" + value + ")", nil
+}
+
+func execute(t *testing.T, source string, resolve Resolver) string {
+	t.Helper()
+
+	tmpl, err := Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := tmpl.Execute(resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"plain text", "plain text"},
+		{"", ""},
+		{"a{%VAR:x%}b", "a(VAR,x)b"},
+		{"{%A%}{%B%}", "(A)(B)"},
+		{"ação {%VAR:ç%}", "ação (VAR,ç)"},
+		{"50%} done", "50%} done"},
+
+		// escapes
+		{`\{%VAR:x%}`, "{%VAR:x%}"},
+		{`a \{% b`, "a {% b"},
+		{`{%REGEX:a\:b:0%}`, "(REGEX,a:b,0)"},
+		{`{%REGEX:a\%}b%}`, "(REGEX,a%}b)"},
+		{`{%REGEX:a\{%b%}`, "(REGEX,a{%b)"},
+		{`outside \: and \%} stay`, `outside \: and \%} stay`},
+
+		// other backslashes are kept
+		{`{%REGEX:a\|b:0%}`, `(REGEX,a\|b,0)`},
+		{`{%REGEX:C\\Users%}`, `(REGEX,C\\Users)`},
+		{`{%REGEX:"id":(\d+):1%}`, `(REGEX,"id",(\d+),1)`},
+		{`{%REGEX:\.\*%}`, `(REGEX,\.\*)`},
+		{`C:\temp`, `C:\temp`},
+
+		// nested tags
+		{"{%VAR:{%ENV:NAME%}:ENDVAR%}", "(VAR,(ENV,NAME),ENDVAR)"},
+		{"{%VAR:x{%ENV:N%}y%}", "(VAR,x(ENV,N)y)"},
+		{"{%A:{%B:{%C%}%}%}", "(A,(B,(C)))"},
+
+		// filters
+		{"{%VAR:x:ENDVAR|raw%}", "(VAR,x,ENDVAR|raw)"},
+		{"{%VAR:{%ENV:N|json%}%}", "(VAR,(ENV,N|json))"},
+		{"{%REGEX:a|b:0%}", "(REGEX,a|b,0)"},
+		{"{%REGEX:a|B%}", "(REGEX,a|B)"},
+		{"{%REGEX:a|%}", "(REGEX,a|)"},
+		{"{%REGEX:a|b1%}", "(REGEX,a|b1)"},
+		{`{%REGEX:a\|raw%}`, `(REGEX,a\|raw)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if value := execute(t, tt.source, echo); value != tt.expected {
+				t.Errorf("got %q, expected %q", value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecuteDoesNotParseValues(t *testing.T) {
+	calls := 0
+	resolve := func(tag *Tag, fields []string) (string, error) {
+		calls++
+
+		switch tag.Name() {
+		case "A":
+			return "{%VAR:x:ENDVAR%}", nil
+		case "B":
+			return "{%C%}", nil
+		case "C":
+			t.Error("a value was parsed again")
+		}
+
+		return echo(tag, fields)
+	}
+
+	if value := execute(t, "{%A%}", resolve); value != "{%VAR:x:ENDVAR%}" {
+		t.Errorf("got %q, expected the value as it is", value)
+	}
+
+	if value := execute(t, "{%VAR:{%B%}%}", resolve); value != "(VAR,{%C%})" {
+		t.Errorf("got %q, expected the value of the field as it is", value)
+	}
+
+	if calls != 3 {
+		t.Errorf("the resolver was called %d times, expected 3", calls)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"{%VAR:x", "column 1: the tag is not closed with %}"},
+		{"{%VAR:x%", "column 1: the tag is not closed with %}"},
+		{"ab{%VAR:{%ENV:x%}", "column 3: the tag is not closed with %}"},
+		{"{%VAR:{%ENV:x", "column 7: the tag is not closed with %}"},
+		{`{%VAR:x\%}`, "column 1: the tag is not closed with %}"},
+		{"ação {%", "column 6: the tag is not closed with %}"},
+		{"a\nbc{%VAR", "line 2, column 3: the tag is not closed with %}"},
+		{"{%%}", "column 1: the tag must start with a name"},
+		{"{%:x%}", "column 1: the tag must start with a name"},
+		{"{% :x%}", "column 1: the tag must start with a name"},
+		{"{%{%ENV:x%}:y%}", "column 1: the tag must start with a name"},
+		{"{%VAR:{%:x%}%}", "column 7: the tag must start with a name"},
+		{"x\n\n  {%|raw%}", "line 3, column 3: the tag must start with a name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Parse(tt.source)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if err.Error() != tt.expected {
+				t.Errorf("got %q, expected %q", err.Error(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAt(t *testing.T) {
+	tests := []struct {
+		source   string
+		column   int
+		expected string
+	}{
+		{"x {%", 5, "column 7: the tag is not closed with %}"},
+		{"{%%}", 10, "column 10: the tag must start with a name"},
+		{"a\n{%", 5, "line 2, column 1: the tag is not closed with %}"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseAt(tt.source, tt.column)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: got %v, expected %q", tt.source, err, tt.expected)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	tmpl, err := Parse("{%A%}\n  {%B:{%C%}%} é {%D%}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]int{
+		"A": {1, 1},
+		"B": {2, 3},
+		"C": {2, 7},
+		"D": {2, 17},
+	}
+
+	var names []string
+
+	err = tmpl.Walk(func(tag *Tag) error {
+		names = append(names, tag.Name())
+
+		if position := [2]int{tag.Line, tag.Column}; position != expected[tag.Name()] {
+			t.Errorf("%s: got %v, expected %v", tag.Name(), position, expected[tag.Name()])
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(names, "") != "ABCD" {
+		t.Errorf("walked %v, expected the tags in order", names)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		source   string
+		err      error
+		expected string
+	}{
+		{"x\n  {%A:{%B%}%}", errBoom, "line 2, column 7: boom"},
+		{"ab{%B%}", errBoom, "column 3: boom"},
+		{"{%B%}", &Error{Line: 3, Column: 4, Message: "kept"}, "line 3, column 4: kept"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tmpl, err := Parse(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = tmpl.Execute(func(tag *Tag, fields []string) (string, error) {
+				if tag.Name() == "B" {
+					return "", tt.err
+				}
+
+				return echo(tag, fields)
+			})
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("got %v, expected %q", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSingleAndLiteral(t *testing.T) {
+	tests := []struct {
+		source  string
+		single  bool
+		literal string
+		isText  bool
+	}{
+		{"{%A%}", true, "", false},
+		{"{%A:{%B%}|raw%}", true, "", false},
+		{"x{%A%}", false, "", false},
+		{"{%A%} ", false, "", false},
+		{"", false, "", true},
+		{"abc", false, "abc", true},
+		{`a\{%b`, false, "a{%b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			tmpl, err := Parse(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, single := tmpl.Single(); single != tt.single {
+				t.Errorf("single: got %v, expected %v", single, tt.single)
+			}
+
+			literal, isText := tmpl.Literal()
+			if literal != tt.literal || isText != tt.isText {
+				t.Errorf("literal: got %q, %v, expected %q, %v", literal, isText, tt.literal, tt.isText)
+			}
+
+			if tmpl.String() != tt.source {
+				t.Errorf("string: got %q, expected the source", tmpl.String())
+			}
+		})
+	}
+}
+
+func TestExecuteValue(t *testing.T) {
+	tmpl, err := Parse(`{%COUNT:{%N%}:a\:b|raw%}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, ok := tmpl.Single()
+	if !ok {
+		t.Fatal("expected a single tag")
+	}
+
+	if tag.Filter != "raw" || tag.Fields[2].String() != `a\:b` {
+		t.Errorf("got the filter %q and the field %q", tag.Filter, tag.Fields[2].String())
+	}
+
+	resolve := func(tag *Tag, fields []string) (string, error) {
+		return "12", nil
+	}
+
+	value, err := tag.ExecuteValue(resolve, func(tag *Tag, fields []string) (interface{}, error) {
+		return strings.Join(fields, "|") + "=" + tag.Name(), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != "COUNT|12|a:b=COUNT" {
+		t.Errorf("got %v, expected the executed fields", value)
+	}
+
+	_, err = tag.ExecuteValue(resolve, func(tag *Tag, fields []string) (interface{}, error) {
+		return nil, errors.New("not a number")
+	})
+	if err == nil || err.Error() != "column 1: not a number" {
+		t.Errorf("got %v, expected the error at the tag", err)
+	}
+}
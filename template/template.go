@@ -0,0 +1,246 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package template parses the {%...%} tags of the test file in a single pass.
+//
+// A tag is a list of fields separated by ":" and closed by "%}". Fields can
+// contain other tags, which are executed first; the values they produce are
+// never parsed again. A tag can end with a filter, a lowercase name after
+// "|" just before the "%}", as in {%VAR:name:ENDVAR|raw%}. Escapes:
+//
+//	\{%   a literal "{%", inside or outside tags
+//	\:    a literal ":" inside a tag
+//	\%}   a literal "%}" inside a tag
+//
+// Any other backslash is kept as it is, so regular expressions and queries
+// in the fields need no extra escaping.
+package template
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Template is a parsed text, safe for concurrent use.
+type Template struct {
+	source string
+	nodes  []node
+}
+
+type node struct {
+	text string
+	tag  *Tag
+}
+
+// Tag is a {%field:field:...%} tag. Line and Column are the position of its
+// "{%" in the source, starting at 1. Filter is the name after "|" at the end
+// of the tag, if any. Compiled is free for the user of the package to keep a
+// value compiled from the fields once, such as a query; it must be set
+// before the template is executed concurrently.
+type Tag struct {
+	Line     int
+	Column   int
+	Fields   []*Template
+	Filter   string
+	Compiled interface{}
+}
+
+// Error is an error of a tag or of the syntax, with its position.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 1 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+// Errorf returns an error at the position of the tag.
+func (t *Tag) Errorf(format string, a ...interface{}) error {
+	return &Error{Line: t.Line, Column: t.Column, Message: fmt.Sprintf(format, a...)}
+}
+
+// Name returns the first field when it has no tags.
+func (t *Tag) Name() string {
+	name, _ := t.Fields[0].Literal()
+
+	return name
+}
+
+// Resolver returns the value of a tag from its executed fields.
+type Resolver func(tag *Tag, fields []string) (string, error)
+
+// ValueResolver returns the value of a tag from its executed fields, with
+// any type.
+type ValueResolver func(tag *Tag, fields []string) (interface{}, error)
+
+// Parse parses the source.
+func Parse(source string) (*Template, error) {
+	return ParseAt(source, 1)
+}
+
+// ParseAt parses a source that starts at the column of a larger text, such
+// as an operand of a condition, so the positions of the first line are
+// relative to that text.
+func ParseAt(source string, column int) (*Template, error) {
+	p := &parser{source: source, column: column}
+
+	t, err := p.parse(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *Template) String() string {
+	return t.source
+}
+
+// Literal returns the text of a template without tags.
+func (t *Template) Literal() (string, bool) {
+	var text strings.Builder
+
+	for _, n := range t.nodes {
+		if n.tag != nil {
+			return "", false
+		}
+
+		text.WriteString(n.text)
+	}
+
+	return text.String(), true
+}
+
+// Single returns the tag of a template that is only one tag, without text.
+func (t *Template) Single() (*Tag, bool) {
+	if len(t.nodes) != 1 || t.nodes[0].tag == nil {
+		return nil, false
+	}
+
+	return t.nodes[0].tag, true
+}
+
+// Scan calls f for each text and each tag of the template, in order, without
+// entering the fields of the tags. tag is nil for a text.
+func (t *Template) Scan(f func(text string, tag *Tag)) {
+	for _, n := range t.nodes {
+		f(n.text, n.tag)
+	}
+}
+
+// Walk calls f for each tag, including the tags inside fields.
+func (t *Template) Walk(f func(tag *Tag) error) error {
+	for _, n := range t.nodes {
+		if n.tag == nil {
+			continue
+		}
+
+		if err := f(n.tag); err != nil {
+			return err
+		}
+
+		for _, field := range n.tag.Fields {
+			if err := field.Walk(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Execute replaces each tag by the value returned by the resolver. Errors of
+// the resolver are returned with the position of the tag.
+func (t *Template) Execute(resolve Resolver) (string, error) {
+	var result strings.Builder
+
+	for _, n := range t.nodes {
+		if n.tag == nil {
+			result.WriteString(n.text)
+			continue
+		}
+
+		value, err := n.tag.execute(resolve)
+		if err != nil {
+			return "", err
+		}
+
+		result.WriteString(value)
+	}
+
+	return result.String(), nil
+}
+
+func (t *Tag) execute(resolve Resolver) (string, error) {
+	fields, err := t.executeFields(resolve)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := resolve(t, fields)
+	if err != nil {
+		return "", t.positioned(err)
+	}
+
+	return value, nil
+}
+
+// ExecuteValue executes the fields of the tag with resolve and returns the
+// value given by value, which keeps its type.
+func (t *Tag) ExecuteValue(resolve Resolver, value ValueResolver) (interface{}, error) {
+	fields, err := t.executeFields(resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := value(t, fields)
+	if err != nil {
+		return nil, t.positioned(err)
+	}
+
+	return result, nil
+}
+
+func (t *Tag) executeFields(resolve Resolver) ([]string, error) {
+	fields := make([]string, len(t.Fields))
+
+	for i, field := range t.Fields {
+		value, err := field.Execute(resolve)
+		if err != nil {
+			return nil, err
+		}
+
+		fields[i] = value
+	}
+
+	return fields, nil
+}
+
+// positioned returns the error with the position of the tag, unless it
+// already has one.
+func (t *Tag) positioned(err error) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	return &Error{Line: t.Line, Column: t.Column, Message: err.Error()}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package xpath evaluates a subset of XPath 1.0 over XML and HTML documents
+// parsed with encoding/xml in non-strict mode:
+//
+//	/html/body/div, //input, .//a, .., *, text(), node(), @name, @*
+//	predicates: [1], [last()], [@type='hidden'], [@id], [contains(@class,'a')]
+//	functions: contains, starts-with, ends-with, not, last, position, count,
+//	normalize-space, string
+//	operators: =, !=, <, <=, >, >=, and, or
+//
+// Element and attribute names are compared ignoring case and namespaces.
+package xpath
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+type NodeType int
+
+const (
+	DocumentNode NodeType = iota
+	ElementNode
+	TextNode
+	AttributeNode
+)
+
+type Node struct {
+	Type     NodeType
+	Name     string
+	Data     string
+	Attr     []*Node
+	Parent   *Node
+	Children []*Node
+}
+
+// Parse reads an XML or HTML document. Void HTML elements are closed
+// automatically and HTML entities are accepted.
+func Parse(r io.Reader) (*Node, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	root := &Node{Type: DocumentNode}
+	current := root
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &Node{Type: ElementNode, Name: t.Name.Local, Parent: current}
+			for _, attr := range t.Attr {
+				node.Attr = append(node.Attr, &Node{Type: AttributeNode, Name: attr.Name.Local, Data: attr.Value, Parent: node})
+			}
+
+			current.Children = append(current.Children, node)
+			current = node
+		case xml.EndElement:
+			for node := current; node.Type == ElementNode; node = node.Parent {
+				if strings.EqualFold(node.Name, t.Name.Local) {
+					current = node.Parent
+					break
+				}
+			}
+		case xml.CharData:
+			current.Children = append(current.Children, &Node{Type: TextNode, Data: string(t), Parent: current})
+		}
+	}
+}
+
+// String returns the string value of the node: the value of an attribute or
+// the text of an element and its descendants.
+func (n *Node) String() string {
+	if n.Type == TextNode || n.Type == AttributeNode {
+		return n.Data
+	}
+
+	var text strings.Builder
+	for _, child := range n.Children {
+		text.WriteString(child.String())
+	}
+
+	return text.String()
+}
+
+func (n *Node) root() *Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+
+	return n
+}
+
+func descendantsOrSelf(nodes []*Node) []*Node {
+	result := make([]*Node, 0, len(nodes))
+
+	var walk func(*Node)
+	walk = func(n *Node) {
+		result = append(result, n)
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	return result
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// functions maps the supported functions to their number of arguments; -1
+// means an optional argument.
+var functions = map[string]int{
+	"last":            0,
+	"position":        0,
+	"count":           1,
+	"not":             1,
+	"string":          -1,
+	"normalize-space": -1,
+	"contains":        2,
+	"starts-with":     2,
+	"ends-with":       2,
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid xpath (%s) at column %d: %s", p.input, p.pos+1, fmt.Sprintf(format, a...))
+}
+
+func (p *parser) end() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	if p.end() {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
+
+func (p *parser) skipSpaces() {
+	for !p.end() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(prefix string) bool {
+	if strings.HasPrefix(p.input[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+
+	return false
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isName(c byte) bool {
+	return isNameStart(c) || c == '-' || c == '.' || c == ':' || c >= '0' && c <= '9'
+}
+
+func (p *parser) name() string {
+	start := p.pos
+	if !isNameStart(p.peek()) {
+		return ""
+	}
+
+	for !p.end() && isName(p.peek()) {
+		p.pos++
+	}
+
+	return p.input[start:p.pos]
+}
+
+// keyword consumes and or or when followed by a space or parenthesis.
+func (p *parser) keyword(word string) bool {
+	p.skipSpaces()
+
+	rest := p.input[p.pos:]
+	if !strings.HasPrefix(rest, word) || len(rest) > len(word) && isName(rest[len(word)]) {
+		return false
+	}
+
+	p.pos += len(word)
+
+	return true
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binary{operator: "or", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binary{operator: "and", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	left, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	for _, operator := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if p.consume(operator) {
+			right, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+
+			return &binary{operator: operator, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseValue() (expr, error) {
+	p.skipSpaces()
+
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		p.pos++
+
+		end := strings.IndexByte(p.input[p.pos:], c)
+		if end < 0 {
+			p.pos--
+			return nil, p.errorf("unterminated string")
+		}
+
+		value := p.input[p.pos : p.pos+end]
+		p.pos += end + 1
+
+		return &literal{value: value}, nil
+	case c >= '0' && c <= '9' || c == '-':
+		start := p.pos
+		p.pos++
+		for !p.end() && (p.peek() >= '0' && p.peek() <= '9' || p.peek() == '.') {
+			p.pos++
+		}
+
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+
+		return &literal{value: value}, nil
+	case c == '(':
+		p.pos++
+
+		value, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+
+		return value, nil
+	}
+
+	start := p.pos
+	name := p.name()
+
+	if arity, found := functions[name]; found && p.consume("(") {
+		return p.parseFunction(start, name, arity)
+	}
+
+	p.pos = start
+
+	return p.parsePath()
+}
+
+// parseFunction parses the arguments of the function whose name starts at
+// start.
+func (p *parser) parseFunction(start int, name string, arity int) (expr, error) {
+	f := &function{name: name}
+
+	p.skipSpaces()
+	for !p.consume(")") {
+		if len(f.arguments) > 0 && !p.consume(",") {
+			return nil, p.errorf("expected , or )")
+		}
+
+		argument, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		f.arguments = append(f.arguments, argument)
+		p.skipSpaces()
+	}
+
+	if arity >= 0 && len(f.arguments) != arity || arity < 0 && len(f.arguments) > 1 {
+		p.pos = start
+		return nil, p.errorf("wrong number of arguments for %s()", name)
+	}
+
+	return f, nil
+}
+
+func (p *parser) parsePath() (expr, error) {
+	pt := new(path)
+	descendant := false
+
+	switch {
+	case p.consume("//"):
+		pt.absolute = true
+		descendant = true
+	case p.consume("/"):
+		pt.absolute = true
+
+		// the root node alone.
+		if !p.startsStep() {
+			return pt, nil
+		}
+	}
+
+	for {
+		st, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+
+		st.descendant = descendant
+		pt.steps = append(pt.steps, st)
+
+		if p.consume("//") {
+			descendant = true
+		} else if p.consume("/") {
+			descendant = false
+		} else {
+			return pt, nil
+		}
+	}
+}
+
+func (p *parser) startsStep() bool {
+	c := p.peek()
+	return c == '.' || c == '@' || c == '*' || isNameStart(c)
+}
+
+func (p *parser) parseStep() (*step, error) {
+	st := new(step)
+
+	switch {
+	case p.consume(".."):
+		st.axis = axisParent
+		st.test = "node()"
+	case p.consume("."):
+		st.axis = axisSelf
+		st.test = "node()"
+	case p.consume("@"):
+		st.axis = axisAttribute
+
+		if p.consume("*") {
+			st.test = "*"
+		} else if st.test = p.name(); st.test == "" {
+			return nil, p.errorf("expected an attribute name")
+		}
+	case p.consume("*"):
+		st.test = "*"
+	default:
+		if st.test = p.name(); st.test == "" {
+			return nil, p.errorf("expected a step")
+		}
+
+		if st.test == "text" || st.test == "node" {
+			if p.consume("()") {
+				st.test += "()"
+			}
+		}
+	}
+
+	for p.consume("[") {
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		if !p.consume("]") {
+			return nil, p.errorf("expected ]")
+		}
+
+		st.predicates = append(st.predicates, predicate)
+	}
+
+	return st, nil
+}
@@ -0,0 +1,376 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpath
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+type axis int
+
+const (
+	axisChild axis = iota
+	axisSelf
+	axisParent
+	axisAttribute
+)
+
+type context struct {
+	node     *Node
+	position int
+	size     int
+}
+
+// expr returns a node-set ([]*Node), a string, a float64 or a bool.
+type expr interface {
+	eval(ctx context) interface{}
+}
+
+type literal struct {
+	value interface{}
+}
+
+type step struct {
+	descendant bool
+	axis       axis
+	test       string
+	predicates []expr
+}
+
+type path struct {
+	absolute bool
+	steps    []*step
+}
+
+type function struct {
+	name      string
+	arguments []expr
+}
+
+type binary struct {
+	operator    string
+	left, right expr
+}
+
+// Expression is a compiled XPath expression, safe for concurrent use.
+type Expression struct {
+	expression string
+	root       expr
+}
+
+// Compile parses the expression.
+func Compile(expression string) (*Expression, error) {
+	p := &parser{input: expression}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if !p.end() {
+		return nil, p.errorf("unexpected character %q", p.peek())
+	}
+
+	return &Expression{expression: expression, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.expression
+}
+
+// Evaluate returns the string value of the result; for node-sets, the value
+// of the first node. It returns false when the node-set is empty.
+func (e *Expression) Evaluate(node *Node) (string, bool) {
+	result := e.root.eval(context{node: node, position: 1, size: 1})
+	if nodes, ok := result.([]*Node); ok && len(nodes) == 0 {
+		return "", false
+	}
+
+	return toString(result), true
+}
+
+func (l *literal) eval(context) interface{} {
+	return l.value
+}
+
+func (p *path) eval(ctx context) interface{} {
+	nodes := []*Node{ctx.node}
+	if p.absolute {
+		nodes = []*Node{ctx.node.root()}
+	}
+
+	for _, st := range p.steps {
+		nodes = st.apply(nodes)
+	}
+
+	return nodes
+}
+
+func (st *step) apply(nodes []*Node) []*Node {
+	if st.descendant {
+		nodes = descendantsOrSelf(nodes)
+	}
+
+	seen := make(map[*Node]bool)
+	result := make([]*Node, 0)
+
+	for _, n := range nodes {
+		candidates := st.candidates(n)
+
+		for _, predicate := range st.predicates {
+			candidates = filter(candidates, predicate)
+		}
+
+		for _, c := range candidates {
+			if !seen[c] {
+				seen[c] = true
+				result = append(result, c)
+			}
+		}
+	}
+
+	return result
+}
+
+func (st *step) candidates(n *Node) []*Node {
+	candidates := make([]*Node, 0)
+
+	switch st.axis {
+	case axisSelf:
+		candidates = append(candidates, n)
+	case axisParent:
+		if n.Parent != nil {
+			candidates = append(candidates, n.Parent)
+		}
+	case axisAttribute:
+		for _, attr := range n.Attr {
+			if st.test == "*" || equalName(attr.Name, st.test) {
+				candidates = append(candidates, attr)
+			}
+		}
+	default:
+		for _, child := range n.Children {
+			if st.matches(child) {
+				candidates = append(candidates, child)
+			}
+		}
+	}
+
+	return candidates
+}
+
+func (st *step) matches(n *Node) bool {
+	switch st.test {
+	case "node()":
+		return true
+	case "text()":
+		return n.Type == TextNode
+	case "*":
+		return n.Type == ElementNode
+	default:
+		return n.Type == ElementNode && equalName(n.Name, st.test)
+	}
+}
+
+func equalName(name, test string) bool {
+	if i := strings.LastIndexByte(test, ':'); i >= 0 {
+		test = test[i+1:]
+	}
+
+	return strings.EqualFold(name, test)
+}
+
+// filter keeps the nodes where the predicate is true; a numeric predicate
+// selects the node at that position.
+func filter(nodes []*Node, predicate expr) []*Node {
+	result := make([]*Node, 0, len(nodes))
+
+	for i, n := range nodes {
+		value := predicate.eval(context{node: n, position: i + 1, size: len(nodes)})
+
+		if number, ok := value.(float64); ok {
+			if number == float64(i+1) {
+				result = append(result, n)
+			}
+		} else if toBool(value) {
+			result = append(result, n)
+		}
+	}
+
+	return result
+}
+
+func (f *function) eval(ctx context) interface{} {
+	arguments := make([]interface{}, len(f.arguments))
+	for i, argument := range f.arguments {
+		arguments[i] = argument.eval(ctx)
+	}
+
+	// functions with an optional argument use the context node.
+	if len(arguments) == 0 {
+		arguments = append(arguments, []*Node{ctx.node})
+	}
+
+	switch f.name {
+	case "last":
+		return float64(ctx.size)
+	case "position":
+		return float64(ctx.position)
+	case "count":
+		nodes, _ := arguments[0].([]*Node)
+		return float64(len(nodes))
+	case "not":
+		return !toBool(arguments[0])
+	case "string":
+		return toString(arguments[0])
+	case "normalize-space":
+		return strings.Join(strings.Fields(toString(arguments[0])), " ")
+	case "contains":
+		return strings.Contains(toString(arguments[0]), toString(arguments[1]))
+	case "starts-with":
+		return strings.HasPrefix(toString(arguments[0]), toString(arguments[1]))
+	default:
+		return strings.HasSuffix(toString(arguments[0]), toString(arguments[1]))
+	}
+}
+
+func (b *binary) eval(ctx context) interface{} {
+	switch b.operator {
+	case "or":
+		return toBool(b.left.eval(ctx)) || toBool(b.right.eval(ctx))
+	case "and":
+		return toBool(b.left.eval(ctx)) && toBool(b.right.eval(ctx))
+	}
+
+	// a comparison with a node-set is true if any of its nodes satisfies it.
+	for _, left := range values(b.left.eval(ctx)) {
+		for _, right := range values(b.right.eval(ctx)) {
+			if compare(left, b.operator, right) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func values(value interface{}) []interface{} {
+	nodes, ok := value.([]*Node)
+	if !ok {
+		return []interface{}{value}
+	}
+
+	result := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		result[i] = n.String()
+	}
+
+	return result
+}
+
+func compare(left interface{}, operator string, right interface{}) bool {
+	if operator == "=" || operator == "!=" {
+		var equal bool
+
+		switch {
+		case isBool(left) || isBool(right):
+			equal = toBool(left) == toBool(right)
+		case isNumber(left) || isNumber(right):
+			equal = toNumber(left) == toNumber(right)
+		default:
+			equal = toString(left) == toString(right)
+		}
+
+		return equal == (operator == "=")
+	}
+
+	l, r := toNumber(left), toNumber(right)
+
+	switch operator {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func isBool(value interface{}) bool {
+	_, ok := value.(bool)
+	return ok
+}
+
+func isNumber(value interface{}) bool {
+	_, ok := value.(float64)
+	return ok
+}
+
+func toBool(value interface{}) bool {
+	switch v := value.(type) {
+	case []*Node:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	default:
+		return v.(bool)
+	}
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case []*Node:
+		if len(v) == 0 {
+			return ""
+		}
+
+		return v[0].String()
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return strconv.FormatBool(v.(bool))
+	}
+}
+
+func toNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+
+		return 0
+	default:
+		number, err := strconv.ParseFloat(strings.TrimSpace(toString(v)), 64)
+		if err != nil {
+			return math.NaN()
+		}
+
+		return number
+	}
+}
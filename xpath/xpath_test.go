@@ -0,0 +1,185 @@
+/*
+Copyright 2022 Gabriel Lasaro.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+ http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xpath
+
+import (
+	"strings"
+	"testing"
+)
+
+const document = `<html>
+<head><title>Login</title></head>
+<body>
+	<form id="login" action="/login">
+		<input type="hidden" name="csrf" value="XYZ789">
+		<input type="text" name="user">
+		<br>
+		<input type="submit" value="Enter">
+	</form>
+	<ul class="items">
+		<li class="item open">one</li>
+		<li class="item">two &amp; more</li>
+		<li class="item open">  three
+			words  </li>
+	</ul>
+	<div><p>a<b>b</b>c</p></div>
+</body>
+</html>`
+
+func parse(t *testing.T, text string) *Node {
+	t.Helper()
+
+	node, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return node
+}
+
+func TestEvaluate(t *testing.T) {
+	root := parse(t, document)
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"/html/head/title", "Login"},
+		{"//title/text()", "Login"},
+		{"//input[@name='csrf']/@value", "XYZ789"},
+		{"//form/@action", "/login"},
+		{"//form/input[2]/@name", "user"},
+		{"//form/input[last()]/@value", "Enter"},
+		{"//input[@type='submit' and @value='Enter']/@type", "submit"},
+		{"//input[@name='x' or @name='user']/@type", "text"},
+		{"//input[@type!='hidden']/@name", "user"},
+		{"//li[2]", "two & more"},
+		{"//li[position()>2]/@class", "item open"},
+		{"//li[contains(@class, 'open')][2]", "  three\n\t\t\twords  "},
+		{"normalize-space(//li[3])", "three words"},
+		{"//li[starts-with(., 'two')]/@class", "item"},
+		{"//li[ends-with(., 'one')]", "one"},
+		{"//li[not(contains(@class, 'open'))]", "two & more"},
+		{"count(//li)", "3"},
+		{"count(//input) > 2", "true"},
+		{"string(//form/@id)", "login"},
+		{"//p", "abc"},
+		{"//b/..", "abc"},
+		{"//b/.", "b"},
+		{"//ul/*[1]", "one"},
+		{"//div//b", "b"},
+		{"//*[@id='login']/input[1]/@name", "csrf"},
+		{"//p/node()[1]", "a"},
+		{"//li[3][normalize-space()='three words']/@class", "item open"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value, found := e.Evaluate(root)
+			if !found {
+				t.Fatal("not found")
+			}
+
+			if value != tt.expected {
+				t.Errorf("got %q, expected %q", value, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvaluateNotFound(t *testing.T) {
+	root := parse(t, document)
+
+	for _, expression := range []string{
+		"//span",
+		"//input[@name='missing']/@value",
+		"//li[4]",
+		"//form/@missing",
+		"/body",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			e, err := Compile(expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value, found := e.Evaluate(root); found {
+				t.Errorf("got %q, expected not found", value)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		column     string
+	}{
+		{"//input[@name='csrf'", "column 21"},
+		{"//input[@name='csrf]", "column 15"},
+		{"//input/@", "column 10"},
+		{"//", "column 3"},
+		{"contains(//a)", "column 1"},
+		{"count(//a", "column 10"},
+		{"//a[1.2.3]", "column 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Compile(tt.expression)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if !strings.Contains(err.Error(), tt.column+":") {
+				t.Errorf("got %q, expected the %s", err.Error(), tt.column)
+			}
+		})
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	root := parse(t, `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body><GetUserResponse><Id>42</Id><![CDATA[<raw>]]></GetUserResponse></soap:Body>
+</soap:Envelope>`)
+
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{"//Id", "42"},
+		{"//GetUserResponse/Id", "42"},
+		{"//Body/GetUserResponse", "42<raw>"},
+	}
+
+	for _, tt := range tests {
+		e, err := Compile(tt.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value, _ := e.Evaluate(root); value != tt.expected {
+			t.Errorf("%s: got %q, expected %q", tt.expression, value, tt.expected)
+		}
+	}
+}